@@ -0,0 +1,159 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Baseline records every migration up to and including version as applied without running it,
+// and updates the current version to version if it is newer. Later migrations are left as they are.
+// It is meant for onboarding an existing database, whose schema is already up to version.
+//
+// Applied migrations whose file changed since, such as the file written after Squash, are recorded again,
+// so that Verify does not report them.
+func (m *Migrator) Baseline(ctx context.Context, version string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error baselining: %w", err)
+		}
+	}()
+
+	return m.withLock(ctx, func() error {
+		s, err := m.lockedState(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !s.has(version) {
+			return errors.New("error finding version " + version)
+		}
+
+		history, err := m.getHistory(ctx)
+		if err != nil {
+			return err
+		}
+		recorded := map[string]string{}
+		for _, entry := range appliedEntries(history) {
+			recorded[entry.Version] = entry.Checksum
+		}
+
+		return m.inTransaction(ctx, func(tx *sql.Tx) error {
+			for _, mig := range s.migrations {
+				if !mig.canApply() || m.compareVersions(mig.version, version) > 0 {
+					continue
+				}
+				if s.applied[mig.version] {
+					if mig.funcs != nil {
+						continue
+					}
+					content, err := fs.ReadFile(mig.fsys, mig.up)
+					if err != nil {
+						return fmt.Errorf("error reading migration file %v: %w", mig.up, err)
+					}
+					if checksum(content) == recorded[mig.version] {
+						continue
+					}
+				}
+				if err := m.recordFile(ctx, tx, mig, DirectionUp); err != nil {
+					return err
+				}
+			}
+
+			if m.compareVersions(version, s.current) <= 0 {
+				return nil
+			}
+			return m.updateVersion(ctx, tx, version)
+		})
+	})
+}
+
+// Squash the migrations up to and including version into a single migration, so that new databases
+// start from a snapshot instead of replaying every file. Up is the up files in order and down the down
+// files in reverse order, each preceded by a comment with its file name. Down is nil if a migration
+// has no down file.
+//
+// Write up and down as the files of version, remove the squashed files, and run Baseline with version
+// on existing databases so that Verify accepts the new file. Verify and Status still report the removed
+// files as missing there. Go migrations and migrations without a transaction cannot be squashed.
+func (m *Migrator) Squash(version string) (up, down []byte, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error squashing: %w", err)
+		}
+	}()
+
+	migrations, err := m.getMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var squashed []migration
+	found := false
+	for _, mig := range migrations {
+		if m.compareVersions(mig.version, version) > 0 {
+			break
+		}
+		if mig.version == version {
+			found = true
+		}
+		if mig.funcs != nil {
+			return nil, nil, fmt.Errorf("error squashing Go migration %v", mig.version)
+		}
+		if mig.up != "" {
+			squashed = append(squashed, mig)
+		}
+	}
+	if !found {
+		return nil, nil, errors.New("error finding version " + version)
+	}
+
+	var upBuf, downBuf bytes.Buffer
+	hasDown := true
+	for i := range squashed {
+		if err := m.appendFile(&upBuf, squashed[i], squashed[i].up); err != nil {
+			return nil, nil, err
+		}
+		mig := squashed[len(squashed)-1-i]
+		if mig.down == "" {
+			hasDown = false
+			continue
+		}
+		if err := m.appendFile(&downBuf, mig, mig.down); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !hasDown {
+		return upBuf.Bytes(), nil, nil
+	}
+	return upBuf.Bytes(), downBuf.Bytes(), nil
+}
+
+// appendFile of mig identified by name to b, with every statement ending with a semicolon,
+// so that it can be followed by another file.
+func (m *Migrator) appendFile(b *bytes.Buffer, mig migration, name string) error {
+	content, err := fs.ReadFile(mig.fsys, name)
+	if err != nil {
+		return fmt.Errorf("error reading migration file %v: %w", name, err)
+	}
+	if noTransactionMatcher.Match(content) {
+		return fmt.Errorf("error squashing %v, as it does not run in a transaction", name)
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "-- %v\n", name)
+	for _, stmt := range splitStatements(string(content), m.dialect == MySQL) {
+		b.WriteString(stmt.query)
+		// a line comment at the end would swallow the semicolon
+		if i := strings.LastIndexByte(stmt.query, '\n'); strings.Contains(stmt.query[i+1:], "--") {
+			b.WriteString("\n")
+		}
+		b.WriteString(";\n")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Dialect of the database, which decides how migrations are locked,
+// and how the migrations tables are created and quoted. It mirrors the flavors of goutils/sqldb.
+type Dialect int
+
+// Supported dialects.
+const (
+	UnknownDialect Dialect = iota
+	MySQL
+	PostgreSQL
+	SQLite
+)
+
+// String returns the name of d.
+func (d Dialect) String() string {
+	switch d {
+	case MySQL:
+		return "MySQL"
+	case PostgreSQL:
+		return "PostgreSQL"
+	case SQLite:
+		return "SQLite"
+	}
+	return "<unknown>"
+}
+
+// detectDialect from the package of the driver of db, such as github.com/lib/pq or github.com/mattn/go-sqlite3.
+func detectDialect(db *sql.DB) Dialect {
+	t := reflect.TypeOf(db.Driver())
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name := strings.ToLower(t.PkgPath() + "." + t.Name())
+	switch {
+	case strings.Contains(name, "sqlite"):
+		return SQLite
+	case strings.Contains(name, "mysql"):
+		return MySQL
+	case strings.Contains(name, "postgres"), strings.Contains(name, "pgx"), strings.Contains(name, "lib/pq"):
+		return PostgreSQL
+	}
+	return UnknownDialect
+}
+
+// quoteTable, which may be qualified by a schema such as app.migrations, as an identifier.
+// PostgreSQL names are folded to lower case first, as PostgreSQL folds unquoted names,
+// so that a table named Migrations is the same migrations table as before quoting.
+// Names are left as is for unknown dialects.
+func (d Dialect) quoteTable(table string) string {
+	var q string
+	switch d {
+	case MySQL:
+		q = "`"
+	case PostgreSQL:
+		q = `"`
+		table = strings.ToLower(table)
+	case SQLite:
+		q = `"`
+	default:
+		return table
+	}
+	return q + strings.ReplaceAll(table, ".", q+"."+q) + q
+}
+
+// placeholder for the nth argument of a query, counting from 1.
+func (d Dialect) placeholder(n int) string {
+	if d == PostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// placeholders for n arguments, separated by commas.
+func (d Dialect) placeholders(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = d.placeholder(i + 1)
+	}
+	return strings.Join(p, ", ")
+}
+
+// textType of columns holding versions, checksums and names.
+func (d Dialect) textType() string {
+	if d == MySQL {
+		return "varchar(255)"
+	}
+	return "text"
+}
+
+// integerType of columns holding sequence numbers and durations.
+func (d Dialect) integerType() string {
+	switch d {
+	case MySQL, PostgreSQL:
+		return "bigint"
+	}
+	return "integer"
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a database driver that records the statements it executes and does not support queries.
+type recorder struct {
+	lock    sync.Mutex
+	queries []string
+}
+
+func (r *recorder) Open(string) (driver.Conn, error)             { return recorderConn{r}, nil }
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return recorderConn{r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return r }
+
+type recorderConn struct {
+	r *recorder
+}
+
+func (c recorderConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recorderConn) Close() error                        { return nil }
+func (c recorderConn) Begin() (driver.Tx, error)           { return recorderTx{}, nil }
+
+func (c recorderConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.lock.Lock()
+	c.r.queries = append(c.r.queries, query)
+	c.r.lock.Unlock()
+	return driver.RowsAffected(0), nil
+}
+
+type recorderTx struct{}
+
+func (recorderTx) Commit() error   { return nil }
+func (recorderTx) Rollback() error { return nil }
+
+func TestCreateSchemas(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		dialect      Dialect
+		table        string
+		historyTable string
+		want         []string
+	}{
+		"postgres": {
+			dialect:      PostgreSQL,
+			table:        "app.migrations",
+			historyTable: "audit.migrations_history",
+			want:         []string{`create schema if not exists "app"`, `create schema if not exists "audit"`},
+		},
+		"postgres unqualified": {
+			dialect: PostgreSQL,
+			table:   "migrations",
+		},
+		"mysql": {
+			dialect: MySQL,
+			table:   "app.migrations",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			rec := &recorder{}
+			db := sql.OpenDB(rec)
+			defer db.Close()
+			m := New(Options{DB: db, Dialect: tt.dialect, FS: fstest.MapFS{}, HistoryTable: tt.historyTable, Table: tt.table})
+
+			tx, err := db.Begin()
+			r.NoError(err)
+			r.NoError(m.createSchemas(context.Background(), tx))
+			r.NoError(tx.Commit())
+			r.Equal(tt.want, rec.queries)
+		})
+	}
+}
+
+func TestQuoteTable(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		dialect Dialect
+		table   string
+		want    string
+	}{
+		"postgres":        {PostgreSQL, "migrations", `"migrations"`},
+		"postgres folded": {PostgreSQL, "Migrations", `"migrations"`},
+		"postgres schema": {PostgreSQL, "App.Migrations", `"app"."migrations"`},
+		"mysql":           {MySQL, "Migrations", "`Migrations`"},
+		"mysql schema":    {MySQL, "app.migrations", "`app`.`migrations`"},
+		"sqlite":          {SQLite, "Migrations", `"Migrations"`},
+		"unknown":         {UnknownDialect, "app.Migrations", "app.Migrations"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.dialect.quoteTable(tt.table))
+		})
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		dialect Dialect
+		want    string
+	}{
+		"postgres": {PostgreSQL, "$1, $2, $3"},
+		"mysql":    {MySQL, "?, ?, ?"},
+		"sqlite":   {SQLite, "?, ?, ?"},
+		"unknown":  {UnknownDialect, "?, ?, ?"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.dialect.placeholders(3))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// EventKind of an Event.
+type EventKind string
+
+const (
+	// EventLocked is sent when the migration lock is acquired, with the time waited for it as Duration.
+	EventLocked EventKind = "locked"
+	// EventStart is sent before running a plan, with the number of Steps in it.
+	EventStart EventKind = "start"
+	// EventSkipped is sent for every migration that the plan does not run, because it is already applied,
+	// not applied, or beyond the target.
+	EventSkipped EventKind = "skipped"
+	// EventApplied is sent after a migration is applied, with its Direction, File and Duration.
+	EventApplied EventKind = "applied"
+	// EventFailed is sent when a migration fails, with the Err and, for SQL files, the Statement and its Line
+	// in File, see StatementError. Go migrations, files of several statements that are not split
+	// and failures outside of the statements have no Statement.
+	EventFailed EventKind = "failed"
+	// EventDone is sent after a plan ran successfully, with the number of Steps and the total Duration.
+	EventDone EventKind = "done"
+)
+
+// Event of migrating, see Options.OnEvent.
+type Event struct {
+	Kind      EventKind
+	Version   string
+	Direction Direction
+	File      string
+	Duration  time.Duration
+	Steps     int
+	Err       error
+	Statement string
+	Line      int
+	// DryRun is set if the migrations are rolled back, see Options.DryRun.
+	DryRun bool
+}
+
+// StatementError is returned when a statement of a migration file fails.
+//
+// If the statements are split, see Options.SplitStatements, Statement is the failing one.
+// Otherwise the file runs in a single call and the failing statement is not known,
+// so Statement is the only statement of the file, or empty with a zero Line if the file has several.
+type StatementError struct {
+	Version   string
+	File      string
+	Statement string
+	// Line of the statement in File, starting at 1, or 0 if Statement is empty.
+	Line int
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *StatementError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("error running migration %v from %v: %v", e.Version, e.File, e.Err)
+	}
+	return fmt.Sprintf("error running migration %v from %v at line %d: %v", e.Version, e.File, e.Line, e.Err)
+}
+
+// Unwrap returns the error of the statement.
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// emit e to Options.OnEvent and Options.Logger, if set.
+func (m *Migrator) emit(ctx context.Context, e Event) {
+	e.DryRun = m.dryRun
+	if m.onEvent != nil {
+		m.onEvent(e)
+	}
+	if m.logger == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	var msg string
+	var attrs []slog.Attr
+	switch e.Kind {
+	case EventLocked:
+		msg = "acquired migration lock"
+		attrs = append(attrs, slog.Duration("waited", e.Duration))
+	case EventStart:
+		msg = "migrating"
+		attrs = append(attrs, slog.Int("steps", e.Steps))
+	case EventSkipped:
+		level, msg = slog.LevelDebug, "skipped migration"
+		attrs = append(attrs, slog.String("version", e.Version))
+	case EventApplied:
+		msg = "applied migration"
+		attrs = append(attrs, slog.String("version", e.Version), slog.String("direction", string(e.Direction)),
+			slog.String("file", e.File), slog.Duration("duration", e.Duration))
+	case EventFailed:
+		level, msg = slog.LevelError, "migration failed"
+		attrs = append(attrs, slog.String("version", e.Version), slog.String("direction", string(e.Direction)),
+			slog.String("file", e.File), slog.Any("error", e.Err))
+		if e.Statement != "" {
+			attrs = append(attrs, slog.String("statement", e.Statement), slog.Int("line", e.Line))
+		}
+	case EventDone:
+		msg = "migrated"
+		attrs = append(attrs, slog.Int("steps", e.Steps), slog.Duration("duration", e.Duration))
+	}
+	if e.DryRun {
+		attrs = append(attrs, slog.Bool("dry_run", true))
+	}
+	m.logger.LogAttrs(ctx, level, msg, attrs...)
+}
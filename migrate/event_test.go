@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFailed(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		content         string
+		splitStatements bool
+		wantStatement   string
+		wantLine        int
+	}{
+		"split": {
+			content:         "-- accounts\ncreate table accounts (id integer);\n\ninsert into nope values (1);\n",
+			splitStatements: true,
+			wantStatement:   "insert into nope values (1)",
+			wantLine:        4,
+		},
+		"no transaction": {
+			content:       "-- migrate:no-transaction\ncreate table accounts (id integer);\ninsert into nope values (1);",
+			wantStatement: "insert into nope values (1)",
+			wantLine:      3,
+		},
+		"single statement": {
+			content:       "-- nope\n\ninsert into nope values (1);\n",
+			wantStatement: "-- nope\n\ninsert into nope values (1)",
+			wantLine:      3,
+		},
+		"several statements": {
+			content: "-- accounts\ncreate table accounts (id integer);\ninsert into nope values (1);\n",
+		},
+		"several statements, first fails": {
+			content: "insert into nope values (1);\ncreate table accounts (id integer);\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.db"))
+			r.NoError(err)
+			defer db.Close()
+
+			var failed []Event
+			m := New(Options{
+				DB: db,
+				FS: fstest.MapFS{"1-accounts.up.sql": {Data: []byte(tt.content)}},
+				OnEvent: func(e Event) {
+					if e.Kind == EventFailed {
+						failed = append(failed, e)
+					}
+				},
+				SplitStatements: tt.splitStatements,
+			})
+			err = m.MigrateUp(context.Background())
+			var stmtErr *StatementError
+			r.True(errors.As(err, &stmtErr))
+			r.Equal("1-accounts", stmtErr.Version)
+			r.Equal("1-accounts.up.sql", stmtErr.File)
+			r.Equal(tt.wantStatement, stmtErr.Statement)
+			r.Equal(tt.wantLine, stmtErr.Line)
+			r.Equal(tt.wantLine != 0, strings.Contains(stmtErr.Error(), "at line"), stmtErr.Error())
+
+			r.Len(failed, 1)
+			r.Equal(tt.wantStatement, failed[0].Statement)
+			r.Equal(tt.wantLine, failed[0].Line)
+			r.ErrorIs(failed[0].Err, stmtErr.Err)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// Force the database to version without running any migrations. Every migration up to and including version
+// is recorded as applied, and every later one as reverted. The empty version records every migration as reverted.
+//
+// Force is meant for recovering after a failed migration, typically one without a transaction,
+// once the database has been fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error forcing version: %w", err)
+		}
+	}()
+
+	return m.withLock(ctx, func() error {
+		s, err := m.lockedState(ctx)
+		if err != nil {
+			return err
+		}
+
+		if version != "" && !s.has(version) {
+			return errors.New("error finding version " + version)
+		}
+
+		return m.inTransaction(ctx, func(tx *sql.Tx) error {
+			for _, mig := range s.migrations {
+				apply := version != "" && m.compareVersions(mig.version, version) <= 0
+				switch {
+				case apply && !s.applied[mig.version] && mig.canApply():
+					if err := m.recordFile(ctx, tx, mig, DirectionUp); err != nil {
+						return err
+					}
+				case !apply && s.applied[mig.version]:
+					if err := m.recordFile(ctx, tx, mig, DirectionDown); err != nil {
+						return err
+					}
+				}
+			}
+
+			return m.updateVersion(ctx, tx, version)
+		})
+	})
+}
+
+// recordFile of mig in direction in the history table without running it. Go migrations have no file.
+func (m *Migrator) recordFile(ctx context.Context, tx *sql.Tx, mig migration, direction Direction) error {
+	name := mig.up
+	if direction == DirectionDown {
+		name = mig.down
+	}
+	var content []byte
+	if name != "" {
+		var err error
+		if content, err = fs.ReadFile(mig.fsys, name); err != nil {
+			return fmt.Errorf("error reading migration file %v: %w", name, err)
+		}
+	}
+	return m.recordHistory(ctx, tx, mig.version, direction, checksum(content), time.Now())
+}
@@ -0,0 +1,14 @@
+module goutils/migrate
+
+go 1.22.4
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.23
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
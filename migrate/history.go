@@ -0,0 +1,219 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"slices"
+	"time"
+)
+
+// Direction of a migration.
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// HistoryEntry is a row of the history table, recorded every time a migration file is applied.
+type HistoryEntry struct {
+	Seq       int64
+	Version   string
+	Direction Direction
+	// Checksum is the hex encoded SHA-256 of the migration file.
+	Checksum      string
+	AppliedAt     time.Time
+	ExecutionTime time.Duration
+	AppliedBy     string
+}
+
+// DriftKind describes how an applied migration differs from the migration files.
+type DriftKind string
+
+const (
+	// ChecksumMismatch means the file was edited after it was applied.
+	ChecksumMismatch DriftKind = "checksum mismatch"
+	// MissingFile means the file of an applied migration does not exist anymore.
+	MissingFile DriftKind = "missing file"
+)
+
+// Drift is an applied migration whose file differs from the one that was applied.
+type Drift struct {
+	Version  string
+	Kind     DriftKind
+	Recorded string
+	Current  string
+}
+
+// String describes d.
+func (d Drift) String() string {
+	if d.Kind == MissingFile {
+		return fmt.Sprintf("%v: %v", d.Version, d.Kind)
+	}
+	return fmt.Sprintf("%v: %v, recorded %v, current %v", d.Version, d.Kind, d.Recorded, d.Current)
+}
+
+// History returns every recorded migration, oldest first.
+func (m *Migrator) History(ctx context.Context) ([]HistoryEntry, error) {
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	return m.getHistory(ctx)
+}
+
+// Verify compares the checksums of the applied up migrations with the files in the FS
+// and returns every migration that drifted. An empty result means no drift.
+// Registered Go migrations are not verified.
+func (m *Migrator) Verify(ctx context.Context) (drifts []Drift, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error verifying migrations: %w", err)
+		}
+	}()
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	history, err := m.getHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := m.getMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[string]migration{}
+	for _, mig := range migrations {
+		byVersion[mig.version] = mig
+	}
+
+	for _, entry := range appliedEntries(history) {
+		mig, ok := byVersion[entry.Version]
+		if ok && mig.funcs != nil {
+			continue
+		}
+		if !ok || mig.up == "" {
+			drifts = append(drifts, Drift{Version: entry.Version, Kind: MissingFile, Recorded: entry.Checksum})
+			continue
+		}
+		content, err := fs.ReadFile(mig.fsys, mig.up)
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration file for version %v: %w", entry.Version, err)
+		}
+		if current := checksum(content); current != entry.Checksum {
+			drifts = append(drifts, Drift{Version: entry.Version, Kind: ChecksumMismatch, Recorded: entry.Checksum, Current: current})
+		}
+	}
+	return drifts, nil
+}
+
+// appliedEntries returns the latest up entry of every version that has not been migrated down since,
+// in the order they were applied.
+func appliedEntries(history []HistoryEntry) []HistoryEntry {
+	applied := map[string]HistoryEntry{}
+	for _, entry := range history {
+		switch entry.Direction {
+		case DirectionUp:
+			applied[entry.Version] = entry
+		case DirectionDown:
+			delete(applied, entry.Version)
+		}
+	}
+	entries := make([]HistoryEntry, 0, len(applied))
+	for _, entry := range applied {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b HistoryEntry) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return entries
+}
+
+// createHistoryTable if it does not exist already.
+func (m *Migrator) createHistoryTable(ctx context.Context, tx *sql.Tx) error {
+	text, integer := m.dialect.textType(), m.dialect.integerType()
+	if _, err := tx.ExecContext(ctx, `create table if not exists `+m.quotedHistoryTable()+` (
+	seq `+integer+` not null,
+	version `+text+` not null,
+	direction `+text+` not null,
+	checksum `+text+` not null,
+	applied_at `+text+` not null,
+	execution_ms `+integer+` not null,
+	applied_by `+text+` not null
+)`); err != nil {
+		return fmt.Errorf("error creating history table %v: %w", m.historyTable, err)
+	}
+	return nil
+}
+
+// recordHistory of applying version in direction, which started at start.
+func (m *Migrator) recordHistory(ctx context.Context, tx *sql.Tx, version string, direction Direction, sum string, start time.Time) error {
+	var seq int64
+	if err := tx.QueryRowContext(ctx, `select coalesce(max(seq), 0) + 1 from `+m.quotedHistoryTable()).Scan(&seq); err != nil {
+		return fmt.Errorf("error getting next history sequence: %w", err)
+	}
+	query := `insert into ` + m.quotedHistoryTable() + ` (seq, version, direction, checksum, applied_at, execution_ms, applied_by) values (` + m.dialect.placeholders(7) + `)`
+	if _, err := tx.ExecContext(ctx, query, seq, version, string(direction), sum, time.Now().UTC().Format(time.RFC3339Nano), time.Since(start).Milliseconds(), m.appliedBy); err != nil {
+		return fmt.Errorf("error recording history of version %v: %w", version, err)
+	}
+	return nil
+}
+
+// getHistory from the history table, oldest first.
+func (m *Migrator) getHistory(ctx context.Context) ([]HistoryEntry, error) {
+	rows, err := m.db.QueryContext(ctx, `select seq, version, direction, checksum, applied_at, execution_ms, applied_by from `+m.quotedHistoryTable()+` order by seq`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting migration history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var appliedAt string
+		var executionMS int64
+		if err := rows.Scan(&entry.Seq, &entry.Version, &entry.Direction, &entry.Checksum, &appliedAt, &executionMS, &entry.AppliedBy); err != nil {
+			return nil, fmt.Errorf("error scanning migration history: %w", err)
+		}
+		if entry.AppliedAt, err = time.Parse(time.RFC3339Nano, appliedAt); err != nil {
+			return nil, fmt.Errorf("error parsing applied_at of version %v: %w", entry.Version, err)
+		}
+		entry.ExecutionTime = time.Duration(executionMS) * time.Millisecond
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting migration history: %w", err)
+	}
+	return history, nil
+}
+
+// quotedHistoryTable name of the history table.
+func (m *Migrator) quotedHistoryTable() string {
+	return m.dialect.quoteTable(m.historyTable)
+}
+
+// checksum of a migration file.
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// currentUser returns the name of the OS user, or the host name if it is unknown.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "unknown"
+}
@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// ErrLockTimeout is returned when the migration lock is not acquired within Options.LockTimeout.
+var ErrLockTimeout = errors.New("timeout acquiring migration lock")
+
+// lockPollInterval between attempts to acquire the lock table on SQLite.
+const lockPollInterval = 50 * time.Millisecond
+
+// withLock runs callback while holding the migration lock, so that only one process migrates at a time.
+//
+// PostgreSQL uses pg_advisory_lock and MySQL uses GET_LOCK, both on a dedicated connection,
+// so the pool must allow more than one open connection. SQLite uses a row in the lock table Table + "_lock",
+// which is left behind if the process dies while migrating and must then be released with ForceUnlock.
+// Databases of an unknown dialect are not locked.
+func (m *Migrator) withLock(ctx context.Context, callback func() error) (err error) {
+	if m.disableLocking {
+		return callback()
+	}
+
+	start := time.Now()
+	var unlock func() error
+	switch m.dialect {
+	case PostgreSQL:
+		unlock, err = m.lockPostgreSQL(ctx)
+	case MySQL:
+		unlock, err = m.lockMySQL(ctx)
+	case SQLite:
+		unlock, err = m.lockSQLite(ctx)
+	default:
+		return callback()
+	}
+	if err != nil {
+		return err
+	}
+	m.emit(ctx, Event{Kind: EventLocked, Duration: time.Since(start)})
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+	return callback()
+}
+
+// lockPostgreSQL with a session level advisory lock keyed by the migrations table.
+func (m *Migrator) lockPostgreSQL(ctx context.Context) (func() error, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting connection for migration lock: %w", err)
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(m.table))
+	key := int64(h.Sum64())
+
+	lockCtx, cancel := m.lockContext(ctx)
+	defer cancel()
+	if _, err := conn.ExecContext(lockCtx, fmt.Sprintf(`select pg_advisory_lock(%d)`, key)); err != nil {
+		_ = conn.Close()
+		return nil, m.lockError(ctx, lockCtx, err)
+	}
+
+	return func() error {
+		defer func() {
+			_ = conn.Close()
+		}()
+		if _, err := conn.ExecContext(context.Background(), fmt.Sprintf(`select pg_advisory_unlock(%d)`, key)); err != nil {
+			return fmt.Errorf("error releasing migration lock: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// lockMySQL with a named lock keyed by the migrations table.
+func (m *Migrator) lockMySQL(ctx context.Context) (func() error, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting connection for migration lock: %w", err)
+	}
+
+	// GET_LOCK takes whole seconds, and a negative timeout waits forever
+	timeout := -1
+	if m.lockTimeout > 0 {
+		timeout = int(math.Ceil(m.lockTimeout.Seconds()))
+	}
+
+	lockCtx, cancel := m.lockContext(ctx)
+	defer cancel()
+	name := "migrate:" + m.table
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(lockCtx, `select get_lock(?, ?)`, name, timeout).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, m.lockError(ctx, lockCtx, err)
+	}
+	if acquired.Int64 != 1 {
+		_ = conn.Close()
+		return nil, ErrLockTimeout
+	}
+
+	return func() error {
+		defer func() {
+			_ = conn.Close()
+		}()
+		if _, err := conn.ExecContext(context.Background(), `select release_lock(?)`, name); err != nil {
+			return fmt.Errorf("error releasing migration lock: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// lockSQLite by inserting the single row of the lock table, polling until it is free.
+// SQLite serializes writes, so only one insert can succeed.
+func (m *Migrator) lockSQLite(ctx context.Context) (func() error, error) {
+	table, err := m.createLockTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCtx, cancel := m.lockContext(ctx)
+	defer cancel()
+	query := `insert into ` + table + ` (id, locked_at, locked_by) select 1, ?, ? where not exists (select * from ` + table + `)`
+	lockedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	for waiting := false; ; waiting = true {
+		res, err := m.db.ExecContext(lockCtx, query, lockedAt, m.appliedBy)
+		if err != nil {
+			return nil, m.lockError(ctx, lockCtx, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("error acquiring migration lock: %w", err)
+		} else if n == 1 {
+			break
+		}
+		if !waiting {
+			m.logLockHolder(ctx, table)
+		}
+
+		select {
+		case <-lockCtx.Done():
+			return nil, m.lockError(ctx, lockCtx, lockCtx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() error {
+		if _, err := m.db.ExecContext(context.Background(), `delete from `+table); err != nil {
+			return fmt.Errorf("error releasing migration lock: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// ForceUnlock releases the migration lock on SQLite, which is left behind if a process dies while migrating,
+// so that the next migration does not wait for it forever. It must only be used when no process is migrating.
+// The locks of PostgreSQL and MySQL are released when the connection of the process closes,
+// so ForceUnlock does nothing on them.
+func (m *Migrator) ForceUnlock(ctx context.Context) error {
+	if m.dialect != SQLite {
+		return nil
+	}
+	table, err := m.createLockTable(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, `delete from `+table); err != nil {
+		return fmt.Errorf("error releasing migration lock: %w", err)
+	}
+	return nil
+}
+
+// createLockTable of SQLite if it does not exist, and return its quoted name.
+func (m *Migrator) createLockTable(ctx context.Context) (string, error) {
+	table := m.dialect.quoteTable(m.table + "_lock")
+	if _, err := m.db.ExecContext(ctx, `create table if not exists `+table+` (
+	id integer primary key,
+	locked_at text not null,
+	locked_by text not null
+)`); err != nil {
+		return "", fmt.Errorf("error creating lock table %v: %w", m.table+"_lock", err)
+	}
+	return table, nil
+}
+
+// logLockHolder of the SQLite lock table, so that a lock left behind by a dead process can be told apart.
+func (m *Migrator) logLockHolder(ctx context.Context, table string) {
+	if m.logger == nil {
+		return
+	}
+	var lockedAt, lockedBy string
+	if err := m.db.QueryRowContext(ctx, `select locked_at, locked_by from `+table).Scan(&lockedAt, &lockedBy); err != nil {
+		return
+	}
+	m.logger.WarnContext(ctx, "waiting for migration lock, release it with ForceUnlock if its process died",
+		slog.String("locked_at", lockedAt), slog.String("locked_by", lockedBy))
+}
+
+// lockContext bounds waiting for the lock by Options.LockTimeout, if set.
+func (m *Migrator) lockContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.lockTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.lockTimeout)
+}
+
+// lockError returns ErrLockTimeout if lockCtx timed out while ctx is still alive.
+func (m *Migrator) lockError(ctx, lockCtx context.Context, err error) error {
+	if ctx.Err() == nil && errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+		return ErrLockTimeout
+	}
+	return fmt.Errorf("error acquiring migration lock: %w", err)
+}
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("1")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	first := New(Options{DB: db, FS: fsys, Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	firstErr := make(chan error, 1)
+	go func() {
+		firstErr <- first.MigrateUp(ctx)
+	}()
+	<-started
+
+	// the second migrator waits for the first one, and then has nothing left to apply
+	second := New(Options{DB: db, FS: fsys})
+	secondErr := make(chan error, 1)
+	go func() {
+		secondErr <- second.MigrateUp(ctx)
+	}()
+	select {
+	case err := <-secondErr:
+		t.Fatalf("second migrator did not wait for the lock: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// a migrator with a lock timeout gives up
+	r.ErrorIs(New(Options{DB: db, FS: fsys, LockTimeout: 50 * time.Millisecond}).MigrateUp(ctx), ErrLockTimeout)
+
+	close(release)
+	r.NoError(<-firstErr)
+	r.NoError(<-secondErr)
+	r.Equal([]string{"t1"}, tables(t, db))
+	history, err := second.History(ctx)
+	r.NoError(err)
+	r.Len(history, 1)
+}
+
+func TestForceUnlock(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(Options{DB: db, FS: testFiles("1"), LockTimeout: 100 * time.Millisecond})
+
+	// a lock left behind by a process that died while migrating
+	_, err := m.lockSQLite(ctx)
+	r.NoError(err)
+	r.ErrorIs(m.MigrateUp(ctx), ErrLockTimeout)
+
+	r.NoError(m.ForceUnlock(ctx))
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"t1"}, tables(t, db))
+
+	// the lock is released after migrating, and releasing a free lock is fine
+	r.NoError(m.ForceUnlock(ctx))
+	r.NoError(m.MigrateUp(ctx))
+}
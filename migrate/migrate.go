@@ -1,6 +1,6 @@
 // Package migrate provides simple migration functions Up, Down, and To, as well as a Migrator.
-// Up, Down, and To are one-liner convenience functions that use default Options.
-// If you need custom Options, use New.
+// Up, Down, and To are one-liner convenience functions that use default Options, modified by any Option.
+// If you need a Migrator, use New.
 package migrate
 
 import (
@@ -9,30 +9,72 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"regexp"
+	"strings"
+	"text/template"
+	"time"
 )
 
 var (
-	upMatcher    = regexp.MustCompile(`^([\w-]+).up.sql$`)
-	downMatcher  = regexp.MustCompile(`^([\w-]+).down.sql`)
+	upMatcher    = regexp.MustCompile(`^([\w.-]+)\.up\.sql$`)
+	downMatcher  = regexp.MustCompile(`^([\w.-]+)\.down\.sql$`)
 	tableMatcher = regexp.MustCompile(`^[\w.]+$`)
+
+	noTransactionMatcher = regexp.MustCompile(`(?m)^--\s*migrate:no-transaction\s*$`)
 )
 
+// ErrDownNotAllowed is returned when running down migrations without Options.AllowDown.
+var ErrDownNotAllowed = errors.New("down migrations are not allowed, set Options.AllowDown")
+
+// Option modifies the default Options of the one-liners Up, Down and To.
+type Option func(opts *Options)
+
+// WithAllowDown allows down migrations, see Options.AllowDown.
+func WithAllowDown() Option {
+	return func(opts *Options) {
+		opts.AllowDown = true
+	}
+}
+
+// WithTable sets Options.Table.
+func WithTable(table string) Option {
+	return func(opts *Options) {
+		opts.Table = table
+	}
+}
+
+// WithVersionParser sets Options.VersionParser.
+func WithVersionParser(parser VersionParser) Option {
+	return func(opts *Options) {
+		opts.VersionParser = parser
+	}
+}
+
+// newWithOptions for the one-liners.
+func newWithOptions(db *sql.DB, fsys fs.FS, opts []Option) *Migrator {
+	o := Options{DB: db, FS: fsys}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return New(o)
+}
+
 // Up from the current version.
-func Up(ctx context.Context, db *sql.DB, fsys fs.FS) error {
-	m := New(Options{DB: db, FS: fsys})
+func Up(ctx context.Context, db *sql.DB, fsys fs.FS, opts ...Option) error {
+	m := newWithOptions(db, fsys, opts)
 	return m.MigrateUp(ctx)
 }
 
-// Down from the current version.
-func Down(ctx context.Context, db *sql.DB, fsys fs.FS) error {
-	m := New(Options{DB: db, FS: fsys})
+// Down from the current version. It needs WithAllowDown.
+func Down(ctx context.Context, db *sql.DB, fsys fs.FS, opts ...Option) error {
+	m := newWithOptions(db, fsys, opts)
 	return m.MigrateDown(ctx)
 }
 
-// To the given version.
-func To(ctx context.Context, db *sql.DB, fsys fs.FS, version string) error {
-	m := New(Options{DB: db, FS: fsys})
+// To the given version. It needs WithAllowDown if the version is older than the current one.
+func To(ctx context.Context, db *sql.DB, fsys fs.FS, version string, opts ...Option) error {
+	m := newWithOptions(db, fsys, opts)
 	return m.MigrateTo(ctx, version)
 }
 
@@ -40,45 +82,153 @@ func To(ctx context.Context, db *sql.DB, fsys fs.FS, version string) error {
 type callback = func(ctx context.Context, tx *sql.Tx, version string) error
 
 type Migrator struct {
-	after  callback
-	before callback
-	db     *sql.DB
-	fs     fs.FS
-	table  string
+	after           callback
+	allowDown       bool
+	allowOutOfOrder bool
+	appliedBy       string
+	before          callback
+	db              *sql.DB
+	dialect         Dialect
+	disableLocking  bool
+	dryRun          bool
+	funcs           map[string]*goMigration
+	historyTable    string
+	lockTimeout     time.Duration
+	logger          *slog.Logger
+	onEvent         func(Event)
+	recursive       bool
+	requireDown     bool
+	sources         []Source
+	splitStatements bool
+	table           string
+	templateData    any
+	versionParser   VersionParser
 }
 
-// Options for New. DB and FS are always required.
+// Options for New. DB and FS or Sources are always required.
 type Options struct {
-	After  callback
-	Before callback
-	DB     *sql.DB
-	FS     fs.FS
-	Table  string
+	After callback
+	// AllowDown allows running down migrations, which are refused with ErrDownNotAllowed otherwise,
+	// as they usually drop data. Dry runs are always allowed.
+	AllowDown bool
+	// AllowOutOfOrder applies unapplied migrations older than the latest applied one of their namespace,
+	// instead of failing with an *OutOfOrderError.
+	AllowOutOfOrder bool
+	// AppliedBy is recorded in the history table for every migration, defaults to the OS user name.
+	AppliedBy string
+	Before    callback
+	DB        *sql.DB
+	// Dialect of DB, detected from its driver if not set. It decides how migrations are locked,
+	// and how the migrations tables are created and quoted. Table names may be schema-qualified,
+	// such as app.migrations, and on PostgreSQL the schema is created if it does not exist.
+	Dialect Dialect
+	// DisableLocking lets several processes migrate at the same time.
+	DisableLocking bool
+	// DryRun runs the migrations in a single transaction that is always rolled back,
+	// to check that they apply. The migrations tables are still created if they do not exist.
+	// Statements that commit implicitly, such as DDL on MySQL, are not rolled back.
+	DryRun bool
+	// Dir in FS with the migration files, defaults to the root of FS.
+	Dir string
+	// FS with the migration files. Their versions are not namespaced.
+	FS fs.FS
+	// HistoryTable records every applied migration, defaults to Table + "_history".
+	HistoryTable string
+	// LockTimeout is how long to wait for another process to finish migrating before failing
+	// with ErrLockTimeout. Zero waits until the context is done.
+	LockTimeout time.Duration
+	// Logger logs the events of migrating, skipped migrations at debug level.
+	Logger *slog.Logger
+	// OnEvent is called with every event of migrating, such as applying a migration.
+	OnEvent func(Event)
+	// Recursive finds migration files in sub-directories too. The version is the file name without
+	// the directory, so directories only organise the files.
+	Recursive bool
+	// RequireDown fails migrating if a migration file has no down file.
+	RequireDown bool
+	// Sources of migration files in addition to FS, such as the migrations of plugins.
+	// The migrations of every source are merged and ordered by version, then namespace.
+	Sources []Source
+	// SplitStatements runs the statements of every migration file one by one, for drivers that
+	// do not support several statements in one call. Files with the -- migrate:no-transaction directive,
+	// which run outside of a transaction, are always split.
+	SplitStatements bool
+	Table           string
+	// TemplateData renders every migration file as a text/template with TemplateData as its data,
+	// for variables such as {{.Schema}} or {{.Prefix}}. Files are not rendered if it is nil.
+	// Checksums are of the files as they are, not as rendered.
+	TemplateData any
+	// VersionParser orders the migrations, defaults to LexicalVersions.
+	// Use IntegerVersions, TimestampVersions or SemverVersions for numeric ordering.
+	VersionParser VersionParser
 }
 
 // New Migrator with Options.
-// If Options.Table is not set, defaults to "migrations". The table name must match ^[\w.]+$ .
+// If Options.Table is not set, defaults to "migrations". The table names must match ^[\w.]+$ .
 // New panics on illegal options.
 func New(opts Options) *Migrator {
-	if opts.DB == nil || opts.FS == nil {
-		panic("DB and FS must be set")
+	if opts.DB == nil || (opts.FS == nil && len(opts.Sources) == 0) {
+		panic("DB and FS or Sources must be set")
+	}
+	var sources []Source
+	if opts.FS != nil {
+		sources = append(sources, newSource(Source{FS: opts.FS, Dir: opts.Dir}))
+	}
+	for _, src := range opts.Sources {
+		sources = append(sources, newSource(src))
 	}
 	if opts.Table == "" {
 		opts.Table = "migrations"
 	}
-	if !tableMatcher.MatchString(opts.Table) {
-		panic("illegal table name " + opts.Table + ", must match " + tableMatcher.String())
+	if opts.HistoryTable == "" {
+		opts.HistoryTable = opts.Table + "_history"
+	}
+	for _, table := range []string{opts.Table, opts.HistoryTable} {
+		if !tableMatcher.MatchString(table) {
+			panic("illegal table name " + table + ", must match " + tableMatcher.String())
+		}
+	}
+	if opts.AppliedBy == "" {
+		opts.AppliedBy = currentUser()
+	}
+	if opts.Dialect == UnknownDialect {
+		opts.Dialect = detectDialect(opts.DB)
+	}
+	if opts.VersionParser == nil {
+		opts.VersionParser = LexicalVersions
 	}
 	return &Migrator{
-		after:  opts.After,
-		before: opts.Before,
-		db:     opts.DB,
-		fs:     opts.FS,
-		table:  opts.Table,
+		after:           opts.After,
+		allowDown:       opts.AllowDown,
+		allowOutOfOrder: opts.AllowOutOfOrder,
+		appliedBy:       opts.AppliedBy,
+		before:          opts.Before,
+		db:              opts.DB,
+		dialect:         opts.Dialect,
+		disableLocking:  opts.DisableLocking,
+		dryRun:          opts.DryRun,
+		historyTable:    opts.HistoryTable,
+		lockTimeout:     opts.LockTimeout,
+		logger:          opts.Logger,
+		onEvent:         opts.OnEvent,
+		recursive:       opts.Recursive,
+		requireDown:     opts.RequireDown,
+		sources:         sources,
+		splitStatements: opts.SplitStatements,
+		table:           opts.Table,
+		templateData:    opts.TemplateData,
+		versionParser:   opts.VersionParser,
 	}
 }
 
 // MigrateUp from the current version.
+//
+// Every migration is tracked individually, so unapplied migrations older than the latest applied one
+// of their namespace are detected. They are applied when Options.AllowOutOfOrder is set, otherwise an *OutOfOrderError
+// listing them is returned.
+//
+// All migrating methods hold a database lock while migrating,
+// so that when several processes start at once, one migrates and the others wait for it.
 func (m *Migrator) MigrateUp(ctx context.Context) (err error) {
 	defer func() {
 		if err != nil {
@@ -86,221 +236,306 @@ func (m *Migrator) MigrateUp(ctx context.Context) (err error) {
 		}
 	}()
 
-	if err := m.createMigrationsTable(ctx); err != nil {
-		return err
-	}
+	return m.migrate(ctx, func(s *state) ([]step, error) {
+		return m.planUp(s, "")
+	})
+}
 
-	currentVersion, err := m.getCurrentVersion(ctx)
-	if err != nil {
-		return err
-	}
+// MigrateDown from the current version.
+func (m *Migrator) MigrateDown(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating down: %w", err)
+		}
+	}()
 
-	names, err := m.getFilenames(upMatcher)
-	if err != nil {
-		return err
-	}
+	return m.migrate(ctx, func(s *state) ([]step, error) {
+		return m.planDown(s, "")
+	})
+}
 
-	for _, name := range names {
-		thisVersion := upMatcher.ReplaceAllString(name, "$1")
-		if thisVersion <= currentVersion {
-			continue
+func (m *Migrator) MigrateTo(ctx context.Context, version string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating to: %w", err)
 		}
+	}()
 
-		if err := m.apply(ctx, name, thisVersion); err != nil {
-			return err
+	if version == "" {
+		return m.MigrateDown(ctx)
+	}
+
+	return m.migrate(ctx, func(s *state) ([]step, error) {
+		return m.planTo(s, version)
+	})
+}
+
+// MigrateUpN applies the next n unapplied migrations.
+func (m *Migrator) MigrateUpN(ctx context.Context, n int) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating up: %w", err)
 		}
+	}()
+
+	if n < 1 {
+		return fmt.Errorf("illegal number of migrations %v, must be positive", n)
 	}
 
-	return nil
+	return m.migrate(ctx, func(s *state) ([]step, error) {
+		steps, err := m.planUp(s, "")
+		return steps[:min(n, len(steps))], err
+	})
 }
 
-// MigrateDown from the current version.
-func (m *Migrator) MigrateDown(ctx context.Context) (err error) {
+// MigrateDownN reverts the latest n applied migrations.
+func (m *Migrator) MigrateDownN(ctx context.Context, n int) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("error migrating down: %w", err)
 		}
 	}()
 
-	if err := m.createMigrationsTable(ctx); err != nil {
-		return err
+	if n < 1 {
+		return fmt.Errorf("illegal number of migrations %v, must be positive", n)
 	}
 
-	currentVersion, err := m.getCurrentVersion(ctx)
-	if err != nil {
-		return err
-	}
+	return m.migrate(ctx, func(s *state) ([]step, error) {
+		steps, err := m.planDown(s, "")
+		return steps[:min(n, len(steps))], err
+	})
+}
 
-	names, err := m.getFilenames(downMatcher)
-	if err != nil {
-		return err
-	}
+// Redo reverts the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error redoing migration: %w", err)
+		}
+	}()
 
-	for i := len(names) - 1; i >= 0; i-- {
-		thisVersion := downMatcher.ReplaceAllString(names[i], "$1")
-		if thisVersion > currentVersion {
-			continue
+	return m.migrate(ctx, func(s *state) ([]step, error) {
+		steps, err := m.planDown(s, "")
+		if err != nil || len(steps) == 0 {
+			return nil, err
 		}
+		down := steps[0]
+		return []step{down, {migration: down.migration, direction: DirectionUp, version: s.current}}, nil
+	})
+}
 
-		nextVersion := ""
-		if i > 0 {
-			nextVersion = downMatcher.ReplaceAllString(names[i-1], "$1")
+// migrate with the steps of plan, holding the lock.
+func (m *Migrator) migrate(ctx context.Context, plan func(s *state) ([]step, error)) error {
+	return m.withLock(ctx, func() error {
+		s, err := m.lockedState(ctx)
+		if err != nil {
+			return err
 		}
 
-		if err := m.apply(ctx, names[i], nextVersion); err != nil {
+		steps, err := plan(s)
+		if err != nil {
 			return err
 		}
-	}
 
-	return nil
-}
+		m.emit(ctx, Event{Kind: EventStart, Steps: len(steps)})
+		planned := map[string]bool{}
+		for _, st := range steps {
+			planned[st.migration.version] = true
+		}
+		for _, mig := range s.migrations {
+			if !planned[mig.version] {
+				m.emit(ctx, Event{Kind: EventSkipped, Version: mig.version})
+			}
+		}
 
-func (m *Migrator) MigrateTo(ctx context.Context, version string) (err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("error migrating to: %w", err)
+		start := time.Now()
+		if err := m.execute(ctx, steps); err != nil {
+			return err
 		}
-	}()
+		m.emit(ctx, Event{Kind: EventDone, Steps: len(steps), Duration: time.Since(start)})
+		return nil
+	})
+}
 
-	if version == "" {
-		return m.MigrateDown(ctx)
-	}
+// script of a migration file or Go migration.
+type script struct {
+	name    string
+	content []byte
+	// query is the content, rendered if Options.TemplateData is set.
+	query string
+	// noTransaction is set by the -- migrate:no-transaction directive or RegisterNoTransaction.
+	noTransaction bool
+	// txFunc or dbFunc is set for Go migrations.
+	txFunc MigrationFunc
+	dbFunc NoTransactionMigrationFunc
+}
 
-	if err := m.createMigrationsTable(ctx); err != nil {
-		return err
+// readScript of the migration file or Go migration of st.
+func (m *Migrator) readScript(st step) (script, error) {
+	if st.migration.funcs != nil {
+		return st.migration.funcs.script(st.migration.version, st.direction), nil
 	}
-
-	currentVersion, err := m.getCurrentVersion(ctx)
+	name := st.file()
+	content, err := fs.ReadFile(st.migration.fsys, name)
 	if err != nil {
-		return err
+		return script{}, fmt.Errorf("error reading migration file %v: %w", name, err)
 	}
-
-	if currentVersion == version {
-		return nil
+	query, err := m.render(name, content)
+	if err != nil {
+		return script{}, err
 	}
+	return script{name: name, content: content, query: query, noTransaction: noTransactionMatcher.Match(content)}, nil
+}
 
-	var matcher *regexp.Regexp
-	if version > currentVersion {
-		matcher = upMatcher
-	} else {
-		matcher = downMatcher
+// render content as a text/template with Options.TemplateData, if it is set.
+func (m *Migrator) render(name string, content []byte) (string, error) {
+	if m.templateData == nil {
+		return string(content), nil
 	}
-	names, err := m.getFilenames(matcher)
+	t, err := template.New(name).Option("missingkey=error").Parse(string(content))
 	if err != nil {
-		return err
+		return "", fmt.Errorf("error parsing migration file %v: %w", name, err)
 	}
-
-	foundVersion := false
-	for _, name := range names {
-		thisVersion := matcher.ReplaceAllString(name, "$1")
-		if thisVersion == version {
-			foundVersion = true
-		}
-	}
-	if !foundVersion {
-		return errors.New("error finding version " + version)
+	var b strings.Builder
+	if err := t.Execute(&b, m.templateData); err != nil {
+		return "", fmt.Errorf("error rendering migration file %v: %w", name, err)
 	}
+	return b.String(), nil
+}
 
-	switch {
-	case version > currentVersion:
-		for _, name := range names {
-			thisVersion := matcher.ReplaceAllString(name, "$1")
-			if thisVersion <= currentVersion {
-				continue
-			}
-			if thisVersion > version {
-				break
-			}
+// execer is a *sql.Tx or *sql.Conn.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
 
-			if err := m.apply(ctx, name, thisVersion); err != nil {
-				return err
-			}
+// apply sc in tx, and update to the version after st.
+func (m *Migrator) apply(ctx context.Context, tx *sql.Tx, st step, sc script) error {
+	if err := m.runBefore(ctx, tx, st, sc); err != nil {
+		return err
+	}
+	start := time.Now()
+	if sc.txFunc != nil {
+		if err := sc.txFunc(ctx, tx); err != nil {
+			return fmt.Errorf("error running migration %v from %v: %w", st.migration.version, sc.name, err)
 		}
-	case version < currentVersion:
-		for i := len(names) - 1; i >= 0; i-- {
-			thisVersion := matcher.ReplaceAllString(names[i], "$1")
-			if thisVersion > currentVersion {
-				continue
-			}
-
-			if thisVersion <= version {
-				break
-			}
+	} else if err := m.run(ctx, tx, st, sc); err != nil {
+		return err
+	}
+	return m.finish(ctx, tx, st, sc, start)
+}
 
-			nextVersion := matcher.ReplaceAllString(names[i-1], "$1")
+// applyWithoutTransaction runs the statements of sc one by one on a dedicated connection,
+// and then updates to the version after st in a transaction.
+// The 'before' callback runs in a transaction of its own before the statements.
+func (m *Migrator) applyWithoutTransaction(ctx context.Context, st step, sc script) error {
+	if m.before != nil {
+		if err := m.inTransaction(ctx, func(tx *sql.Tx) error {
+			return m.runBefore(ctx, tx, st, sc)
+		}); err != nil {
+			return err
+		}
+	}
 
-			if err := m.apply(ctx, names[i], nextVersion); err != nil {
-				return err
-			}
+	start := time.Now()
+	if sc.dbFunc != nil {
+		if err := sc.dbFunc(ctx, m.db); err != nil {
+			return fmt.Errorf("error running migration %v from %v: %w", st.migration.version, sc.name, err)
+		}
+	} else {
+		conn, err := m.db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting connection for migration %v: %w", st.migration.version, err)
+		}
+		err = m.run(ctx, conn, st, sc)
+		_ = conn.Close()
+		if err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return m.inTransaction(ctx, func(tx *sql.Tx) error {
+		return m.finish(ctx, tx, st, sc, start)
+	})
 }
 
-// apply a file identified by name and update to version.
-func (m *Migrator) apply(ctx context.Context, name, version string) error {
-	content, err := fs.ReadFile(m.fs, name)
-	if err != nil {
-		return fmt.Errorf("error reading migration file %v: %w", name, err)
+// runBefore runs the 'before' callback, if any.
+func (m *Migrator) runBefore(ctx context.Context, tx *sql.Tx, st step, sc script) error {
+	if m.before == nil {
+		return nil
 	}
+	if err := m.before(ctx, tx, st.version); err != nil {
+		return fmt.Errorf("error in 'before' callback when applying version %v from %v: %w", st.version, sc.name, err)
+	}
+	return nil
+}
 
-	return m.inTransaction(ctx, func(tx *sql.Tx) error {
-		if m.before != nil {
-			if err := m.before(ctx, tx, version); err != nil {
-				return fmt.Errorf("error in 'before' callback when applying version %v from %v: %w", version, name, err)
+// run the statements of sc. They are split and run one by one for files without a transaction,
+// or if Options.SplitStatements is set.
+//
+// Failures are returned as a *StatementError. If the statements are split, it has the failing statement.
+// Otherwise the file ran in one call, and it has the statement if the file has only one,
+// or else only the file, as the driver does not tell which statement failed.
+func (m *Migrator) run(ctx context.Context, e execer, st step, sc script) error {
+	version := st.migration.version
+	statements := splitStatements(sc.query, m.dialect == MySQL)
+	if !sc.noTransaction && !m.splitStatements {
+		if _, err := e.ExecContext(ctx, sc.query); err != nil {
+			stmtErr := &StatementError{Version: version, File: sc.name, Err: err}
+			if len(statements) == 1 {
+				stmtErr.Statement, stmtErr.Line = statements[0].query, statements[0].line
 			}
+			return stmtErr
 		}
+		return nil
+	}
 
-		// Normally we wouldn't just string interpolate the version like this,
-		// but because we know the version has been matched against the regexes, we know it's safe.
-		if _, err := tx.ExecContext(ctx, `update `+m.table+` set version = '`+version+`'`); err != nil {
-			return fmt.Errorf("error updating version to %v: %w", version, err)
-		}
-		if _, err := tx.ExecContext(ctx, string(content)); err != nil {
-			return fmt.Errorf("error running migration %v from %v: %w", version, name, err)
-		}
-
-		if m.after != nil {
-			if err := m.after(ctx, tx, version); err != nil {
-				return fmt.Errorf("error in 'after' callback when applying version %v from %v: %w", version, name, err)
-			}
+	for _, stmt := range statements {
+		if _, err := e.ExecContext(ctx, stmt.query); err != nil {
+			return &StatementError{Version: version, File: sc.name, Statement: stmt.query, Line: stmt.line, Err: err}
 		}
-		return nil
-	})
+	}
+	return nil
 }
 
-// getFilenames alphabetically where the name matches the given matcher.
-func (m *Migrator) getFilenames(matcher *regexp.Regexp) ([]string, error) {
-	var names []string
-	entries, err := fs.ReadDir(m.fs, ".")
-	if err != nil {
-		return names, err
+// finish applying sc by updating to the version after st, recording the history and running the 'after' callback.
+func (m *Migrator) finish(ctx context.Context, tx *sql.Tx, st step, sc script, start time.Time) error {
+	version := st.version
+	if err := m.updateVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	if err := m.recordHistory(ctx, tx, st.migration.version, st.direction, checksum(sc.content), start); err != nil {
+		return err
 	}
 
-	for _, entry := range entries {
-		if !matcher.MatchString(entry.Name()) {
-			continue
+	if m.after != nil {
+		if err := m.after(ctx, tx, version); err != nil {
+			return fmt.Errorf("error in 'after' callback when applying version %v from %v: %w", version, sc.name, err)
 		}
-		names = append(names, entry.Name())
 	}
-	return names, nil
+	return nil
 }
 
-// createMigrationsTable if it does not exist already, and insert the empty version if it's empty.
+// createMigrationsTable and the history table if they do not exist already, and insert the empty version if it's empty.
+// On PostgreSQL, the schemas of schema-qualified tables are created as well.
 func (m *Migrator) createMigrationsTable(ctx context.Context) error {
 	return m.inTransaction(ctx, func(tx *sql.Tx) error {
-		if _, err := tx.ExecContext(ctx, `create table if not exists `+m.table+` (version text not null)`); err != nil {
+		if err := m.createSchemas(ctx, tx); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `create table if not exists `+m.quotedTable()+` (version `+m.dialect.textType()+` not null)`); err != nil {
 			return fmt.Errorf("error creating migrations table %v: %w", m.table, err)
 		}
+		if err := m.createHistoryTable(ctx, tx); err != nil {
+			return err
+		}
 
-		var exists bool
-		if err := tx.QueryRowContext(ctx, `select exists (select * from `+m.table+`)`).Scan(&exists); err != nil {
+		var count int
+		if err := tx.QueryRowContext(ctx, `select count(*) from `+m.quotedTable()).Scan(&count); err != nil {
 			return err
 		}
 
-		if !exists {
-			if _, err := tx.ExecContext(ctx, `insert into `+m.table+` values ('')`); err != nil {
+		if count == 0 {
+			if _, err := tx.ExecContext(ctx, `insert into `+m.quotedTable()+` values ('')`); err != nil {
 				return err
 			}
 		}
@@ -308,15 +543,45 @@ func (m *Migrator) createMigrationsTable(ctx context.Context) error {
 	})
 }
 
+// createSchemas of the tables on PostgreSQL, if they are schema-qualified.
+func (m *Migrator) createSchemas(ctx context.Context, tx *sql.Tx) error {
+	if m.dialect != PostgreSQL {
+		return nil
+	}
+	for _, table := range []string{m.table, m.historyTable} {
+		schema, _, ok := strings.Cut(table, ".")
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `create schema if not exists `+m.dialect.quoteTable(schema)); err != nil {
+			return fmt.Errorf("error creating schema %v: %w", schema, err)
+		}
+	}
+	return nil
+}
+
 // getCurrentVersion from the migrations table.
 func (m *Migrator) getCurrentVersion(ctx context.Context) (string, error) {
 	var version string
-	if err := m.db.QueryRowContext(ctx, `select version from `+m.table+``).Scan(&version); err != nil {
+	if err := m.db.QueryRowContext(ctx, `select version from `+m.quotedTable()).Scan(&version); err != nil {
 		return "", fmt.Errorf("error getting current migration version: %w", err)
 	}
 	return version, nil
 }
 
+// updateVersion in the migrations table.
+func (m *Migrator) updateVersion(ctx context.Context, tx *sql.Tx, version string) error {
+	if _, err := tx.ExecContext(ctx, `update `+m.quotedTable()+` set version = `+m.dialect.placeholder(1), version); err != nil {
+		return fmt.Errorf("error updating version to %v: %w", version, err)
+	}
+	return nil
+}
+
+// quotedTable name of the migrations table.
+func (m *Migrator) quotedTable() string {
+	return m.dialect.quoteTable(m.table)
+}
+
 func (m *Migrator) inTransaction(ctx context.Context, callback func(tx *sql.Tx) error) (err error) {
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -0,0 +1,605 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// openDB opens a new SQLite database in a temporary directory.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+// testFiles with an up file creating the table t<version> and a down file dropping it for every version.
+func testFiles(versions ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, version := range versions {
+		fsys[version+".up.sql"] = &fstest.MapFile{Data: []byte("create table t" + version + " (id integer);")}
+		fsys[version+".down.sql"] = &fstest.MapFile{Data: []byte("drop table t" + version + ";")}
+	}
+	return fsys
+}
+
+// tables in db other than the migrations tables, in name order.
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`select name from sqlite_master where type = 'table' and name not like 'migrations%' order by name`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	return names
+}
+
+// currentVersion in the migrations table of db.
+func currentVersion(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var version string
+	require.NoError(t, db.QueryRow(`select version from migrations`).Scan(&version))
+	return version
+}
+
+func TestMigrator(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(Options{AllowDown: true, DB: db, FS: testFiles("1", "2", "3")})
+
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"t1", "t2", "t3"}, tables(t, db))
+	r.Equal("3", currentVersion(t, db))
+
+	// migrating again does nothing
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal("3", currentVersion(t, db))
+
+	r.NoError(m.MigrateTo(ctx, "1"))
+	r.Equal([]string{"t1"}, tables(t, db))
+	r.Equal("1", currentVersion(t, db))
+
+	r.NoError(m.MigrateUpN(ctx, 1))
+	r.Equal([]string{"t1", "t2"}, tables(t, db))
+
+	r.NoError(m.Redo(ctx))
+	r.Equal([]string{"t1", "t2"}, tables(t, db))
+	r.Equal("2", currentVersion(t, db))
+
+	r.NoError(m.MigrateDownN(ctx, 1))
+	r.Equal([]string{"t1"}, tables(t, db))
+
+	r.NoError(m.MigrateDown(ctx))
+	r.Empty(tables(t, db))
+	r.Equal("", currentVersion(t, db))
+
+	r.Error(m.MigrateTo(ctx, "4"))
+}
+
+func TestDownNotAllowed(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(Options{DB: db, FS: testFiles("1", "2")})
+
+	r.NoError(m.MigrateUp(ctx))
+	r.ErrorIs(m.MigrateDown(ctx), ErrDownNotAllowed)
+	r.ErrorIs(m.MigrateTo(ctx, "1"), ErrDownNotAllowed)
+	r.Equal([]string{"t1", "t2"}, tables(t, db))
+
+	// dry runs are always allowed
+	r.NoError(New(Options{DB: db, DryRun: true, FS: testFiles("1", "2")}).MigrateDown(ctx))
+	r.Equal([]string{"t1", "t2"}, tables(t, db))
+}
+
+func TestOneLiners(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("2", "10")
+
+	r.NoError(To(ctx, db, fsys, "2", WithVersionParser(IntegerVersions)))
+	r.Equal([]string{"t2"}, tables(t, db))
+	r.NoError(Up(ctx, db, fsys, WithVersionParser(IntegerVersions)))
+	r.Equal([]string{"t10", "t2"}, tables(t, db))
+	r.ErrorIs(Down(ctx, db, fsys, WithVersionParser(IntegerVersions)), ErrDownNotAllowed)
+	r.NoError(Down(ctx, db, fsys, WithAllowDown(), WithVersionParser(IntegerVersions)))
+	r.Empty(tables(t, db))
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("1", "2")
+	m := New(Options{AllowDown: true, AppliedBy: `o'brien\`, DB: db, FS: fsys})
+
+	r.NoError(m.MigrateUp(ctx))
+	r.NoError(m.MigrateTo(ctx, "1"))
+	history, err := m.History(ctx)
+	r.NoError(err)
+	r.Len(history, 3)
+	for i, want := range []struct {
+		version   string
+		direction Direction
+		file      string
+	}{
+		{"1", DirectionUp, "1.up.sql"},
+		{"2", DirectionUp, "2.up.sql"},
+		{"2", DirectionDown, "2.down.sql"},
+	} {
+		r.Equal(want.version, history[i].Version)
+		r.Equal(want.direction, history[i].Direction)
+		r.Equal(checksum(fsys[want.file].Data), history[i].Checksum)
+		r.Equal(`o'brien\`, history[i].AppliedBy)
+		r.False(history[i].AppliedAt.IsZero())
+	}
+
+	drifts, err := m.Verify(ctx)
+	r.NoError(err)
+	r.Empty(drifts)
+
+	// reverted migrations are not verified
+	fsys["2.up.sql"] = &fstest.MapFile{Data: []byte("create table t2 (id integer, name text);")}
+	drifts, err = m.Verify(ctx)
+	r.NoError(err)
+	r.Empty(drifts)
+
+	fsys["1.up.sql"] = &fstest.MapFile{Data: []byte("create table t1 (id integer, name text);")}
+	drifts, err = m.Verify(ctx)
+	r.NoError(err)
+	r.Equal([]Drift{{Version: "1", Kind: ChecksumMismatch, Recorded: history[0].Checksum, Current: checksum(fsys["1.up.sql"].Data)}}, drifts)
+
+	delete(fsys, "1.up.sql")
+	drifts, err = m.Verify(ctx)
+	r.NoError(err)
+	r.Equal([]Drift{{Version: "1", Kind: MissingFile, Recorded: history[0].Checksum}}, drifts)
+}
+
+func TestFailedMigration(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("1")
+	fsys["2.up.sql"] = &fstest.MapFile{Data: []byte("create table t2 (id integer);\ninsert into nope values (1);")}
+
+	r.Error(New(Options{DB: db, FS: fsys}).MigrateUp(ctx))
+	// the failed migration is rolled back as a whole
+	r.Equal([]string{"t1"}, tables(t, db))
+	r.Equal("1", currentVersion(t, db))
+	history, err := New(Options{DB: db, FS: fsys}).History(ctx)
+	r.NoError(err)
+	r.Len(history, 1)
+}
+
+func TestOutOfOrder(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+
+	r.NoError(New(Options{DB: db, FS: testFiles("1", "3")}).MigrateUp(ctx))
+
+	// 2 is merged from a parallel branch after 3 was applied
+	fsys := testFiles("1", "2", "3", "4")
+	err := New(Options{DB: db, FS: fsys}).MigrateUp(ctx)
+	var outOfOrder *OutOfOrderError
+	r.True(errors.As(err, &outOfOrder))
+	r.Equal([]string{"2"}, outOfOrder.Versions)
+	r.Equal([]string{"t1", "t3"}, tables(t, db))
+
+	r.NoError(New(Options{AllowOutOfOrder: true, DB: db, FS: fsys}).MigrateUp(ctx))
+	r.Equal([]string{"t1", "t2", "t3", "t4"}, tables(t, db))
+	r.Equal("4", currentVersion(t, db))
+
+	// migrating to an older version reverts the migrations after it, in reverse order
+	r.NoError(New(Options{AllowDown: true, DB: db, FS: fsys}).MigrateTo(ctx, "2"))
+	r.Equal([]string{"t1", "t2"}, tables(t, db))
+	r.Equal("2", currentVersion(t, db))
+}
+
+func TestIntegerVersions(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(Options{DB: db, FS: testFiles("2", "10"), VersionParser: IntegerVersions})
+
+	r.NoError(m.MigrateTo(ctx, "2"))
+	r.Equal([]string{"t2"}, tables(t, db))
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"t10", "t2"}, tables(t, db))
+	r.Equal("10", currentVersion(t, db))
+
+	// versions with the same integer are duplicates
+	fsys := testFiles("1", "01")
+	r.ErrorIs(New(Options{DB: db, FS: fsys, Table: "other", VersionParser: IntegerVersions}).MigrateUp(ctx), ErrDuplicateVersion)
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+
+	r.NoError(New(Options{DB: db, FS: testFiles("1", "2")}).MigrateUp(ctx))
+
+	// 1 was removed after it was applied
+	fsys := testFiles("2", "3")
+	m := New(Options{DB: db, FS: fsys})
+	statuses, err := m.Status(ctx)
+	r.NoError(err)
+	r.Len(statuses, 3)
+	for i, want := range []MigrationStatus{
+		{Version: "1", Kind: StatusMissingFile},
+		{Version: "2", Kind: StatusApplied},
+		{Version: "3", Kind: StatusPending},
+	} {
+		r.Equal(want.Version, statuses[i].Version)
+		r.Equal(want.Kind, statuses[i].Kind)
+		r.Equal(want.Kind == StatusPending, statuses[i].AppliedAt.IsZero())
+	}
+
+	plan, err := m.Plan(ctx, Latest)
+	r.NoError(err)
+	r.Equal([]Step{{Version: "3", Direction: DirectionUp, File: "3.up.sql"}}, plan)
+	plan, err = m.Plan(ctx, "")
+	r.NoError(err)
+	r.Equal([]Step{{Version: "2", Direction: DirectionDown, File: "2.down.sql"}}, plan)
+
+	// numeric versions are planned in order
+	m = New(Options{DB: openDB(t), FS: testFiles("2", "10"), VersionParser: IntegerVersions})
+	plan, err = m.Plan(ctx, Latest)
+	r.NoError(err)
+	r.Equal([]Step{
+		{Version: "2", Direction: DirectionUp, File: "2.up.sql"},
+		{Version: "10", Direction: DirectionUp, File: "10.up.sql"},
+	}, plan)
+}
+
+func TestBackfill(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+
+	// a database migrated to 2 before the history table existed
+	r.NoError(New(Options{DB: db, FS: testFiles("1", "2")}).MigrateUp(ctx))
+	_, err := db.Exec(`delete from migrations_history`)
+	r.NoError(err)
+
+	// reading the state does not record the backfilled history
+	m := New(Options{DB: db, FS: testFiles("1", "2", "3")})
+	statuses, err := m.Status(ctx)
+	r.NoError(err)
+	r.Len(statuses, 3)
+	for i, kind := range []StatusKind{StatusApplied, StatusApplied, StatusPending} {
+		r.Equal(kind, statuses[i].Kind)
+		r.True(statuses[i].AppliedAt.IsZero())
+	}
+	plan, err := m.Plan(ctx, Latest)
+	r.NoError(err)
+	r.Equal([]Step{{Version: "3", Direction: DirectionUp, File: "3.up.sql"}}, plan)
+	drifts, err := m.Verify(ctx)
+	r.NoError(err)
+	r.Empty(drifts)
+	r.NoError(New(Options{DB: db, FS: testFiles("1", "2", "3"), DryRun: true}).MigrateUp(ctx))
+	history, err := m.History(ctx)
+	r.NoError(err)
+	r.Empty(history)
+	r.Equal("2", currentVersion(t, db))
+
+	// migrating records it
+	r.NoError(m.MigrateUp(ctx))
+	history, err = m.History(ctx)
+	r.NoError(err)
+	r.Len(history, 3)
+	for i, version := range []string{"1", "2", "3"} {
+		r.Equal(version, history[i].Version)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("1", "2")
+
+	r.NoError(New(Options{DB: db, DryRun: true, FS: fsys}).MigrateUp(ctx))
+	r.Empty(tables(t, db))
+	r.Equal("", currentVersion(t, db))
+
+	fsys["3.up.sql"] = &fstest.MapFile{Data: []byte("create table broken (")}
+	r.Error(New(Options{DB: db, DryRun: true, FS: fsys}).MigrateUp(ctx))
+	r.Empty(tables(t, db))
+}
+
+func TestNoTransaction(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("1")
+	fsys["2.up.sql"] = &fstest.MapFile{Data: []byte("-- migrate:no-transaction\ninsert into t1 (id) values (1);\nvacuum;")}
+	fsys["2.down.sql"] = &fstest.MapFile{Data: []byte("-- migrate:no-transaction\ndelete from t1;\ninsert into nope values (1);")}
+	m := New(Options{AllowDown: true, DB: db, FS: fsys})
+
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal("2", currentVersion(t, db))
+
+	// statements before the failing one are not rolled back, and the version is kept
+	r.Error(m.MigrateDownN(ctx, 1))
+	var n int
+	r.NoError(db.QueryRow("select count(*) from t1").Scan(&n))
+	r.Equal(0, n)
+	r.Equal("2", currentVersion(t, db))
+}
+
+func TestSplitStatementsOption(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := fstest.MapFS{"1.up.sql": {Data: []byte("create table t1 (id integer);\n" +
+		"create trigger t1_insert after insert on t1 begin insert into t2 (id) values (new.id); end;\n" +
+		"create table t2 (id integer);\ninsert into t1 (id) values (1);")}}
+
+	r.NoError(New(Options{DB: db, FS: fsys, SplitStatements: true}).MigrateUp(ctx))
+	var n int
+	r.NoError(db.QueryRow("select count(*) from t2").Scan(&n))
+	r.Equal(1, n)
+}
+
+func TestGoMigrations(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(Options{AllowDown: true, DB: db, FS: testFiles("1", "3")})
+	m.Register("2", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into t1 (id) values (1)")
+		return err
+	}, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "delete from t1")
+		return err
+	})
+
+	r.NoError(m.MigrateUp(ctx))
+	var n int
+	r.NoError(db.QueryRow("select count(*) from t1").Scan(&n))
+	r.Equal(1, n)
+
+	r.NoError(m.MigrateTo(ctx, "1"))
+	r.NoError(db.QueryRow("select count(*) from t1").Scan(&n))
+	r.Equal(0, n)
+
+	// a migration file with the same version is a duplicate
+	fsys := testFiles("1", "2")
+	dup := New(Options{DB: db, FS: fsys, Table: "other"})
+	dup.Register("2", func(ctx context.Context, tx *sql.Tx) error { return nil }, nil)
+	r.ErrorIs(dup.MigrateUp(ctx), ErrDuplicateVersion)
+}
+
+func TestForce(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(Options{DB: db, FS: testFiles("1", "2", "3")})
+
+	r.NoError(m.Force(ctx, "2"))
+	r.Empty(tables(t, db))
+	r.Equal("2", currentVersion(t, db))
+	statuses, err := m.Status(ctx)
+	r.NoError(err)
+	r.Equal(StatusApplied, statuses[1].Kind)
+	r.Equal(StatusPending, statuses[2].Kind)
+
+	r.NoError(m.Force(ctx, ""))
+	r.Equal("", currentVersion(t, db))
+	r.Error(m.Force(ctx, "4"))
+
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"t1", "t2", "t3"}, tables(t, db))
+}
+
+func TestTemplateData(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table {{.Prefix}}accounts (id integer);")},
+		"1.down.sql": {Data: []byte("drop table {{.Prefix}}accounts;")},
+	}
+	m := New(Options{AllowDown: true, DB: db, FS: fsys, TemplateData: map[string]string{"Prefix": "app_"}})
+
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"app_accounts"}, tables(t, db))
+
+	// checksums are of the files as they are
+	history, err := m.History(ctx)
+	r.NoError(err)
+	r.Equal(checksum(fsys["1.up.sql"].Data), history[0].Checksum)
+	drifts, err := m.Verify(ctx)
+	r.NoError(err)
+	r.Empty(drifts)
+
+	r.NoError(m.MigrateDown(ctx))
+	r.Empty(tables(t, db))
+
+	// missing keys are errors
+	m = New(Options{DB: db, FS: fsys, TemplateData: map[string]string{}})
+	r.ErrorContains(m.MigrateUp(ctx), "error rendering migration file 1.up.sql")
+	r.Empty(tables(t, db))
+}
+
+func TestSources(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	core := fstest.MapFS{
+		"migrations/1.up.sql":            {Data: []byte("create table t1 (id integer);")},
+		"migrations/1.down.sql":          {Data: []byte("drop table t1;")},
+		"migrations/2024/3.up.sql":       {Data: []byte("create table t3 (id integer);")},
+		"migrations/2024/3.down.sql":     {Data: []byte("drop table t3;")},
+		"other/9.up.sql":                 {Data: []byte("create table t9 (id integer);")},
+		"migrations/2024/readme.md":      {Data: []byte("not a migration")},
+		"migrations/2024/old/2.up.sql":   {Data: []byte("create table t2 (id integer);")},
+		"migrations/2024/old/2.down.sql": {Data: []byte("drop table t2;")},
+	}
+	plugin := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table plugin1 (id integer);")},
+		"1.down.sql": {Data: []byte("drop table plugin1;")},
+		"3.up.sql":   {Data: []byte("create table plugin3 (id integer);")},
+		"3.down.sql": {Data: []byte("drop table plugin3;")},
+	}
+	m := New(Options{
+		AllowDown: true,
+		DB:        db,
+		Dir:       "migrations",
+		FS:        core,
+		Recursive: true,
+		Sources:   []Source{{FS: plugin, Namespace: "plugin"}},
+	})
+
+	// the migrations of every source are ordered by version, then namespace
+	plan, err := m.Plan(ctx, Latest)
+	r.NoError(err)
+	r.Equal([]Step{
+		{Version: "1", Direction: DirectionUp, File: "1.up.sql"},
+		{Version: "plugin/1", Direction: DirectionUp, File: "1.up.sql"},
+		{Version: "2", Direction: DirectionUp, File: "2024/old/2.up.sql"},
+		{Version: "3", Direction: DirectionUp, File: "2024/3.up.sql"},
+		{Version: "plugin/3", Direction: DirectionUp, File: "3.up.sql"},
+	}, plan)
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"plugin1", "plugin3", "t1", "t2", "t3"}, tables(t, db))
+	r.Equal("plugin/3", currentVersion(t, db))
+
+	drifts, err := m.Verify(ctx)
+	r.NoError(err)
+	r.Empty(drifts)
+
+	r.NoError(m.MigrateTo(ctx, "plugin/1"))
+	r.Equal([]string{"plugin1", "t1"}, tables(t, db))
+
+	// a source added later starts with its first migration, although it is older than the applied ones
+	other := fstest.MapFS{"0.up.sql": {Data: []byte("create table other0 (id integer);")}}
+	m = New(Options{Dir: "migrations", DB: db, FS: core, Recursive: true, Sources: []Source{{FS: plugin, Namespace: "plugin"}, {FS: other, Namespace: "other"}}})
+	r.NoError(m.MigrateUp(ctx))
+	r.Equal([]string{"other0", "plugin1", "plugin3", "t1", "t2", "t3"}, tables(t, db))
+	r.Equal("plugin/3", currentVersion(t, db))
+
+	// without Recursive only the files of Dir are found
+	plan, err = New(Options{DB: openDB(t), Dir: "migrations", FS: core}).Plan(ctx, Latest)
+	r.NoError(err)
+	r.Equal([]Step{{Version: "1", Direction: DirectionUp, File: "1.up.sql"}}, plan)
+
+	// the same version in two directories is a duplicate
+	core["migrations/2024/old/1.up.sql"] = &fstest.MapFile{Data: []byte("create table t1 (id integer);")}
+	_, err = New(Options{DB: openDB(t), Dir: "migrations", FS: core, Recursive: true}).Plan(ctx, Latest)
+	r.ErrorIs(err, ErrDuplicateVersion)
+
+	// RequireDown fails if an up file has no down file
+	_, err = New(Options{DB: openDB(t), Dir: "migrations/2024", FS: core, Recursive: true, RequireDown: true}).Plan(ctx, Latest)
+	r.ErrorContains(err, "error finding down migrations for versions 1")
+}
+
+func TestBaselineAndSquash(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := testFiles("1", "2", "3")
+	m := New(Options{AllowDown: true, DB: db, FS: fsys})
+	r.NoError(m.MigrateTo(ctx, "2"))
+
+	up, down, err := m.Squash("2")
+	r.NoError(err)
+	r.Contains(string(up), "create table t1")
+	r.Contains(string(up), "create table t2")
+	r.Contains(string(down), "drop table t2")
+
+	// replace 1 and 2 with the squashed file and baseline the existing database
+	delete(fsys, "1.up.sql")
+	delete(fsys, "1.down.sql")
+	fsys["2.up.sql"] = &fstest.MapFile{Data: up}
+	fsys["2.down.sql"] = &fstest.MapFile{Data: down}
+	r.NoError(m.Baseline(ctx, "2"))
+	drifts, err := m.Verify(ctx)
+	r.NoError(err)
+	r.Equal([]Drift{{Version: "1", Kind: MissingFile, Recorded: checksum(testFiles("1")["1.up.sql"].Data)}}, drifts)
+
+	// a new database starts from the squashed file
+	fresh := openDB(t)
+	r.NoError(New(Options{DB: fresh, FS: fsys}).MigrateUp(ctx))
+	r.Equal([]string{"t1", "t2", "t3"}, tables(t, fresh))
+
+	// baselining an existing schema records the migrations without running them
+	existing := openDB(t)
+	_, err = existing.Exec(string(up))
+	r.NoError(err)
+	r.NoError(New(Options{DB: existing, FS: fsys}).Baseline(ctx, "2"))
+	r.Equal("2", currentVersion(t, existing))
+	r.NoError(New(Options{DB: existing, FS: fsys}).MigrateUp(ctx))
+	r.Equal([]string{"t1", "t2", "t3"}, tables(t, existing))
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	var events []Event
+	m := New(Options{DB: db, FS: testFiles("1", "2"), OnEvent: func(e Event) {
+		events = append(events, e)
+	}})
+
+	r.NoError(m.MigrateUpN(ctx, 1))
+	var kinds []EventKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	r.Equal([]EventKind{EventLocked, EventStart, EventSkipped, EventApplied, EventDone}, kinds)
+	r.Equal(1, events[1].Steps)
+	r.Equal("2", events[2].Version)
+	r.Equal(Event{Kind: EventApplied, Version: "1", Direction: DirectionUp, File: "1.up.sql", Duration: events[3].Duration}, events[3])
+	r.Equal(1, events[4].Steps)
+
+	events = nil
+	fsys := testFiles("1", "2")
+	fsys["2.up.sql"] = &fstest.MapFile{Data: []byte("insert into nope values (1);")}
+	m = New(Options{DB: db, FS: fsys, OnEvent: func(e Event) {
+		events = append(events, e)
+	}})
+	err := m.MigrateUp(ctx)
+	r.Error(err)
+	last := events[len(events)-1]
+	r.Equal(EventFailed, last.Kind)
+	r.Equal("2", last.Version)
+	r.ErrorContains(err, last.Err.Error())
+}
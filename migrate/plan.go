@@ -0,0 +1,448 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OutOfOrderError is returned when migrations older than the latest applied one of their namespace have not been
+// applied, typically because they were merged from a parallel branch. Set Options.AllowOutOfOrder to apply them.
+type OutOfOrderError struct {
+	Versions []string
+}
+
+// Error implements the error interface.
+func (e *OutOfOrderError) Error() string {
+	return "unapplied migrations older than the latest applied version: " + strings.Join(e.Versions, ", ")
+}
+
+// Latest is the target of Plan that applies every migration, like MigrateUp.
+// The empty target reverts every migration, like MigrateDown.
+const Latest = "@latest"
+
+// Step of a plan, which applies the migration File of Version in Direction.
+// File is empty for Go migrations, see Migrator.Register.
+type Step struct {
+	Version   string
+	Direction Direction
+	File      string
+}
+
+// migration is a version with its up and down files.
+type migration struct {
+	version   string
+	namespace string
+	key       VersionKey
+	// source and its fsys with the up and down files
+	source int
+	fsys   fs.FS
+	up     string
+	down   string
+	// funcs of a Go migration, which has no files.
+	funcs *goMigration
+}
+
+// canApply is true if mig has an up file or is a Go migration.
+func (mig migration) canApply() bool {
+	return mig.up != "" || mig.funcs != nil
+}
+
+// canRevert is true if mig has a down file or a Go down function.
+func (mig migration) canRevert() bool {
+	return mig.down != "" || (mig.funcs != nil && mig.funcs.canRevert())
+}
+
+// step of a plan, which applies a single migration in a direction.
+type step struct {
+	migration migration
+	direction Direction
+	// version is the current version after the step.
+	version string
+}
+
+// state of the database compared to the migration files.
+type state struct {
+	// migrations in version order.
+	migrations []migration
+	applied    map[string]bool
+	// current version in the migrations table, which is the latest applied version.
+	current string
+	// backfill is set when applied is derived from current, as the history is empty.
+	backfill bool
+}
+
+// clone s, so that a plan can be computed on top of another one.
+func (s *state) clone() *state {
+	applied := make(map[string]bool, len(s.applied))
+	for version := range s.applied {
+		applied[version] = true
+	}
+	return &state{migrations: s.migrations, applied: applied, current: s.current, backfill: s.backfill}
+}
+
+// has is true if there is a migration with version.
+func (s *state) has(version string) bool {
+	for _, mig := range s.migrations {
+		if mig.version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// compareVersions in migration order, which is by version key and then namespace.
+// Versions the parser rejects, such as a current version recorded before the parser was configured,
+// are compared as plain strings.
+func (m *Migrator) compareVersions(a, b string) int {
+	namespaceA, versionA := splitNamespace(a)
+	namespaceB, versionB := splitNamespace(b)
+	keyA, errA := m.versionParser(versionA)
+	keyB, errB := m.versionParser(versionB)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	if c := keyA.Compare(keyB); c != 0 {
+		return c
+	}
+	return strings.Compare(namespaceA, namespaceB)
+}
+
+// getMigrations from the sources, in version order.
+func (m *Migrator) getMigrations() ([]migration, error) {
+	files, err := m.getFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[string]*migration{}
+	var migrations []*migration
+	for _, f := range files {
+		mig, ok := byVersion[f.version]
+		if !ok {
+			mig = &migration{version: f.version, source: f.source, fsys: f.fsys}
+			byVersion[f.version] = mig
+			migrations = append(migrations, mig)
+		}
+		name := &mig.down
+		if f.up {
+			name = &mig.up
+		}
+		if *name != "" || mig.source != f.source {
+			return nil, fmt.Errorf("%w %v: found more than one file", ErrDuplicateVersion, f.version)
+		}
+		*name = f.path
+	}
+
+	for version, funcs := range m.funcs {
+		if _, ok := byVersion[version]; ok {
+			return nil, fmt.Errorf("%w %v: registered as a Go migration and as a file", ErrDuplicateVersion, version)
+		}
+		migrations = append(migrations, &migration{version: version, funcs: funcs})
+	}
+
+	sorted := make([]migration, 0, len(migrations))
+	for _, mig := range migrations {
+		var version string
+		mig.namespace, version = splitNamespace(mig.version)
+		key, err := m.versionParser(version)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing version of migration %v: %w", mig.version, err)
+		}
+		mig.key = key
+		sorted = append(sorted, *mig)
+	}
+	slices.SortFunc(sorted, func(a, b migration) int {
+		if c := a.key.Compare(b.key); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.namespace, b.namespace); c != 0 {
+			return c
+		}
+		return strings.Compare(a.version, b.version)
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].key.Compare(sorted[i].key) == 0 && sorted[i-1].namespace == sorted[i].namespace {
+			return nil, fmt.Errorf("%w %v: %v and %v", ErrDuplicateVersion, sorted[i].key, sorted[i-1].version, sorted[i].version)
+		}
+	}
+	if m.requireDown {
+		if err := checkDownFiles(sorted); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// getState of the database, creating the migrations tables if needed.
+//
+// Databases migrated before the history table existed only know their current version,
+// every migration up to it is considered applied. It is only recorded in the history table by lockedState,
+// so that reading the state, as Plan and Status do, does not write to the database.
+func (m *Migrator) getState(ctx context.Context) (*state, error) {
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	migrations, err := m.getMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := m.getHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &state{migrations: migrations, applied: map[string]bool{}, current: current}
+	if len(history) == 0 && current != "" {
+		s.backfill = true
+		for _, mig := range migrations {
+			if mig.canApply() && m.compareVersions(mig.version, current) <= 0 {
+				s.applied[mig.version] = true
+			}
+		}
+		return s, nil
+	}
+	for _, entry := range appliedEntries(history) {
+		s.applied[entry.Version] = true
+	}
+	return s, nil
+}
+
+// lockedState is the state of the database for changing it, which must hold the lock.
+// The migrations that getState considers applied without history are recorded, unless it is a dry run.
+func (m *Migrator) lockedState(ctx context.Context) (*state, error) {
+	s, err := m.getState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.backfill && !m.dryRun {
+		if err := m.backfillHistory(ctx, s); err != nil {
+			return nil, err
+		}
+		s.backfill = false
+	}
+	return s, nil
+}
+
+// backfillHistory records every migration that s considers applied.
+func (m *Migrator) backfillHistory(ctx context.Context, s *state) error {
+	return m.inTransaction(ctx, func(tx *sql.Tx) error {
+		for _, mig := range s.migrations {
+			if !s.applied[mig.version] {
+				continue
+			}
+			if err := m.recordFile(ctx, tx, mig, DirectionUp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// planUp applies every unapplied migration up to and including target, or all of them if target is empty.
+//
+// Holes are unapplied migrations older than the latest applied one of the same namespace, so that a source
+// added later, such as a plugin, starts with its first migration whatever the versions of the other sources.
+func (m *Migrator) planUp(s *state, target string) ([]step, error) {
+	latest := map[string]int{}
+	for i, mig := range s.migrations {
+		if s.applied[mig.version] {
+			latest[mig.namespace] = i
+		}
+	}
+
+	var steps []step
+	var holes []string
+	current := s.current
+	for i, mig := range s.migrations {
+		if target != "" && m.compareVersions(mig.version, target) > 0 {
+			break
+		}
+		if s.applied[mig.version] || !mig.canApply() {
+			continue
+		}
+		if l, ok := latest[mig.namespace]; ok && i < l {
+			holes = append(holes, mig.version)
+		}
+		if m.compareVersions(mig.version, current) > 0 {
+			current = mig.version
+		}
+		steps = append(steps, step{migration: mig, direction: DirectionUp, version: current})
+	}
+	if len(holes) > 0 && !m.allowOutOfOrder {
+		return nil, &OutOfOrderError{Versions: holes}
+	}
+	return steps, nil
+}
+
+// planDown reverts every applied migration newer than target, or all of them if target is empty.
+func (m *Migrator) planDown(s *state, target string) ([]step, error) {
+	var steps []step
+	for i := len(s.migrations) - 1; i >= 0; i-- {
+		mig := s.migrations[i]
+		if target != "" && m.compareVersions(mig.version, target) <= 0 {
+			break
+		}
+		if !s.applied[mig.version] {
+			continue
+		}
+		if !mig.canRevert() {
+			return nil, fmt.Errorf("error finding down migration for version %v", mig.version)
+		}
+
+		// the current version becomes the latest applied migration before this one
+		previous := ""
+		for j := i - 1; j >= 0; j-- {
+			if s.applied[s.migrations[j].version] {
+				previous = s.migrations[j].version
+				break
+			}
+		}
+		steps = append(steps, step{migration: mig, direction: DirectionDown, version: previous})
+	}
+	return steps, nil
+}
+
+// planTo reverts the applied migrations newer than target, and applies the unapplied ones up to it.
+func (m *Migrator) planTo(s *state, target string) ([]step, error) {
+	if !s.has(target) {
+		return nil, errors.New("error finding version " + target)
+	}
+
+	down, err := m.planDown(s, target)
+	if err != nil {
+		return nil, err
+	}
+	after := s.clone()
+	for _, st := range down {
+		delete(after.applied, st.migration.version)
+		after.current = st.version
+	}
+	up, err := m.planUp(after, target)
+	if err != nil {
+		return nil, err
+	}
+	return append(down, up...), nil
+}
+
+// Plan returns the steps that migrating to target would run, in order, without running them.
+// Target is Latest for MigrateUp, empty for MigrateDown, or a version for MigrateTo.
+func (m *Migrator) Plan(ctx context.Context, target string) (plan []Step, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error planning migrations: %w", err)
+		}
+	}()
+
+	s, err := m.getState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []step
+	switch target {
+	case Latest:
+		steps, err = m.planUp(s, "")
+	case "":
+		steps, err = m.planDown(s, "")
+	default:
+		steps, err = m.planTo(s, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, st := range steps {
+		plan = append(plan, Step{Version: st.migration.version, Direction: st.direction, File: st.file()})
+	}
+	return plan, nil
+}
+
+// file of the migration that st applies.
+func (st step) file() string {
+	if st.direction == DirectionDown {
+		return st.migration.down
+	}
+	return st.migration.up
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// execute the steps of a plan in order, each in its own transaction unless the file opts out of it.
+// With Options.DryRun, all steps run in a single transaction that is always rolled back.
+func (m *Migrator) execute(ctx context.Context, steps []step) error {
+	if !m.allowDown && !m.dryRun {
+		for _, st := range steps {
+			if st.direction == DirectionDown {
+				return ErrDownNotAllowed
+			}
+		}
+	}
+
+	if m.dryRun {
+		err := m.inTransaction(ctx, func(tx *sql.Tx) error {
+			for _, st := range steps {
+				if err := m.executeStep(ctx, st, func(sc script) error {
+					if sc.noTransaction {
+						return fmt.Errorf("error dry running %v, as it does not run in a transaction", sc.name)
+					}
+					return m.apply(ctx, tx, st, sc)
+				}); err != nil {
+					return err
+				}
+			}
+			return errDryRun
+		})
+		if err == errDryRun {
+			return nil
+		}
+		return err
+	}
+
+	for _, st := range steps {
+		if err := m.executeStep(ctx, st, func(sc script) error {
+			if sc.noTransaction {
+				return m.applyWithoutTransaction(ctx, st, sc)
+			}
+			return m.inTransaction(ctx, func(tx *sql.Tx) error {
+				return m.apply(ctx, tx, st, sc)
+			})
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeStep reads the script of st and applies it, and emits an event with the outcome.
+func (m *Migrator) executeStep(ctx context.Context, st step, apply func(sc script) error) error {
+	start := time.Now()
+	sc, err := m.readScript(st)
+	if err == nil {
+		err = apply(sc)
+	}
+
+	e := Event{Kind: EventApplied, Version: st.migration.version, Direction: st.direction, File: st.file(), Duration: time.Since(start)}
+	if err != nil {
+		e.Kind, e.Err = EventFailed, err
+		var stmtErr *StatementError
+		if errors.As(err, &stmtErr) {
+			e.Statement, e.Line = stmtErr.Statement, stmtErr.Line
+		}
+	}
+	m.emit(ctx, e)
+	return err
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testState of the migrations 1 to 4 with up and down files, ordered as integers so 10 would come last,
+// where applied are applied and the current version is the latest of them.
+func testState(applied ...string) *state {
+	s := &state{applied: map[string]bool{}}
+	for _, version := range []string{"1", "2", "3", "4"} {
+		key, _ := IntegerVersions(version)
+		s.migrations = append(s.migrations, migration{version: version, key: key, up: version + ".up.sql", down: version + ".down.sql"})
+	}
+	for _, version := range applied {
+		s.applied[version] = true
+		s.current = version
+	}
+	return s
+}
+
+// formatSteps as "version direction -> current version" for comparing plans.
+func formatSteps(steps []step) []string {
+	var formatted []string
+	for _, st := range steps {
+		formatted = append(formatted, fmt.Sprintf("%v %v -> %v", st.migration.version, st.direction, st.version))
+	}
+	return formatted
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+	type planFunc = func(m *Migrator, s *state, target string) ([]step, error)
+	planUp := (*Migrator).planUp
+	planDown := (*Migrator).planDown
+	planTo := (*Migrator).planTo
+	tests := map[string]struct {
+		plan            planFunc
+		state           *state
+		target          string
+		allowOutOfOrder bool
+		want            []string
+		wantErr         string
+		wantOutOfOrder  []string
+	}{
+		"up": {
+			plan:  planUp,
+			state: testState(),
+			want:  []string{"1 up -> 1", "2 up -> 2", "3 up -> 3", "4 up -> 4"},
+		},
+		"up from current": {
+			plan:  planUp,
+			state: testState("1", "2"),
+			want:  []string{"3 up -> 3", "4 up -> 4"},
+		},
+		"up to target": {
+			plan:   planUp,
+			state:  testState("1"),
+			target: "3",
+			want:   []string{"2 up -> 2", "3 up -> 3"},
+		},
+		"up applied": {
+			plan:  planUp,
+			state: testState("1", "2", "3", "4"),
+		},
+		"up hole": {
+			plan:           planUp,
+			state:          testState("1", "3"),
+			wantOutOfOrder: []string{"2"},
+		},
+		"up holes": {
+			plan:           planUp,
+			state:          testState("3"),
+			wantOutOfOrder: []string{"1", "2"},
+		},
+		"up hole out of order": {
+			plan:            planUp,
+			state:           testState("1", "3"),
+			allowOutOfOrder: true,
+			want:            []string{"2 up -> 3", "4 up -> 4"},
+		},
+		"up hole before target": {
+			plan:            planUp,
+			state:           testState("1", "3"),
+			target:          "2",
+			allowOutOfOrder: true,
+			want:            []string{"2 up -> 3"},
+		},
+		"down": {
+			plan:  planDown,
+			state: testState("1", "2", "3"),
+			want:  []string{"3 down -> 2", "2 down -> 1", "1 down -> "},
+		},
+		"down to target": {
+			plan:   planDown,
+			state:  testState("1", "2", "3"),
+			target: "1",
+			want:   []string{"3 down -> 2", "2 down -> 1"},
+		},
+		"down hole": {
+			plan:  planDown,
+			state: testState("1", "3"),
+			want:  []string{"3 down -> 1", "1 down -> "},
+		},
+		"down nothing applied": {
+			plan:  planDown,
+			state: testState(),
+		},
+		"to down": {
+			plan:   planTo,
+			state:  testState("1", "2", "3"),
+			target: "1",
+			want:   []string{"3 down -> 2", "2 down -> 1"},
+		},
+		"to up": {
+			plan:   planTo,
+			state:  testState("1"),
+			target: "3",
+			want:   []string{"2 up -> 2", "3 up -> 3"},
+		},
+		"to current": {
+			plan:   planTo,
+			state:  testState("1", "2"),
+			target: "2",
+		},
+		"to hole": {
+			plan:   planTo,
+			state:  testState("1", "3"),
+			target: "2",
+			want:   []string{"3 down -> 1", "2 up -> 2"},
+		},
+		"to hole below": {
+			plan:           planTo,
+			state:          testState("2", "3"),
+			target:         "2",
+			wantOutOfOrder: []string{"1"},
+		},
+		"to hole below out of order": {
+			plan:            planTo,
+			state:           testState("2", "3"),
+			target:          "2",
+			allowOutOfOrder: true,
+			want:            []string{"3 down -> 2", "1 up -> 2"},
+		},
+		"to unknown": {
+			plan:    planTo,
+			state:   testState("1"),
+			target:  "5",
+			wantErr: "error finding version 5",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			m := &Migrator{allowOutOfOrder: tt.allowOutOfOrder, versionParser: IntegerVersions}
+			steps, err := tt.plan(m, tt.state, tt.target)
+			switch {
+			case tt.wantOutOfOrder != nil:
+				var outOfOrder *OutOfOrderError
+				r.True(errors.As(err, &outOfOrder), "%v", err)
+				r.Equal(tt.wantOutOfOrder, outOfOrder.Versions)
+			case tt.wantErr != "":
+				r.EqualError(err, tt.wantErr)
+			default:
+				r.NoError(err)
+				r.Equal(tt.want, formatSteps(steps))
+			}
+		})
+	}
+}
+
+func TestPlanMissingFiles(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	m := &Migrator{versionParser: IntegerVersions}
+
+	// migrations without an up file are not applied
+	s := testState("1")
+	s.migrations[1].up = ""
+	steps, err := m.planUp(s, "")
+	r.NoError(err)
+	r.Equal([]string{"3 up -> 3", "4 up -> 4"}, formatSteps(steps))
+
+	// migrations without a down file cannot be reverted
+	s = testState("1", "2", "3")
+	s.migrations[1].down = ""
+	_, err = m.planDown(s, "")
+	r.EqualError(err, "error finding down migration for version 2")
+	steps, err = m.planDown(s, "2")
+	r.NoError(err)
+	r.Equal([]string{"3 down -> 2"}, formatSteps(steps))
+}
+
+func TestPlanNamespaces(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	m := &Migrator{versionParser: IntegerVersions}
+	s := &state{applied: map[string]bool{"1": true, "2": true, "plugin/2": true}, current: "plugin/2"}
+	for _, version := range []string{"0", "plugin/0", "1", "plugin/1", "2", "plugin/2", "3"} {
+		namespace, rest := splitNamespace(version)
+		key, _ := IntegerVersions(rest)
+		s.migrations = append(s.migrations, migration{version: version, namespace: namespace, key: key, up: rest + ".up.sql"})
+	}
+
+	// holes are only detected within a namespace
+	_, err := m.planUp(s, "")
+	var outOfOrder *OutOfOrderError
+	r.True(errors.As(err, &outOfOrder), "%v", err)
+	r.Equal([]string{"0", "plugin/0", "plugin/1"}, outOfOrder.Versions)
+
+	// a namespace without applied migrations has no holes
+	delete(s.applied, "plugin/2")
+	s.current = "2"
+	_, err = m.planUp(s, "")
+	r.True(errors.As(err, &outOfOrder), "%v", err)
+	r.Equal([]string{"0"}, outOfOrder.Versions)
+
+	s.applied["0"] = true
+	steps, err := m.planUp(s, "")
+	r.NoError(err)
+	r.Equal([]string{"plugin/0 up -> 2", "plugin/1 up -> 2", "plugin/2 up -> plugin/2", "3 up -> 3"}, formatSteps(steps))
+}
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+)
+
+var versionMatcher = regexp.MustCompile(`^[\w.-]+$`)
+
+// MigrationFunc is a migration written in Go, which runs in tx.
+type MigrationFunc = func(ctx context.Context, tx *sql.Tx) error
+
+// NoTransactionMigrationFunc is a migration written in Go, which runs outside of a transaction.
+type NoTransactionMigrationFunc = func(ctx context.Context, db *sql.DB) error
+
+// goMigration holds the functions of a registered migration.
+// Either up and down or noTxUp and noTxDown are set.
+type goMigration struct {
+	up, down         MigrationFunc
+	noTxUp, noTxDown NoTransactionMigrationFunc
+}
+
+// Register a Go migration for version, which is ordered among the migration files by Options.VersionParser.
+// It is recorded in the history table and runs the Before and After callbacks like a migration file.
+// Down may be nil if the migration cannot be reverted.
+//
+// Register must be called before migrating. It panics if the version is illegal, must match ^[\w.-]+$,
+// already registered, or up is nil. A migration file with the same version is reported when migrating.
+func (m *Migrator) Register(version string, up, down MigrationFunc) {
+	if up == nil {
+		panic("up must be set for version " + version)
+	}
+	m.register(version, &goMigration{up: up, down: down})
+}
+
+// RegisterNoTransaction registers a Go migration like Register, which runs outside of a transaction.
+// The Before callback runs in a transaction before it, and the After callback in a transaction after it.
+func (m *Migrator) RegisterNoTransaction(version string, up, down NoTransactionMigrationFunc) {
+	if up == nil {
+		panic("up must be set for version " + version)
+	}
+	m.register(version, &goMigration{noTxUp: up, noTxDown: down})
+}
+
+func (m *Migrator) register(version string, gm *goMigration) {
+	if !versionMatcher.MatchString(version) {
+		panic("illegal version " + version + ", must match " + versionMatcher.String())
+	}
+	if m.funcs == nil {
+		m.funcs = map[string]*goMigration{}
+	}
+	if _, ok := m.funcs[version]; ok {
+		panic("version " + version + " is already registered")
+	}
+	m.funcs[version] = gm
+}
+
+// canRevert is true if gm has a down function.
+func (gm *goMigration) canRevert() bool {
+	return gm.down != nil || gm.noTxDown != nil
+}
+
+// script of the function of gm in direction.
+func (gm *goMigration) script(version string, direction Direction) script {
+	sc := script{name: version + " (go)"}
+	switch direction {
+	case DirectionUp:
+		sc.txFunc, sc.dbFunc = gm.up, gm.noTxUp
+	case DirectionDown:
+		sc.txFunc, sc.dbFunc = gm.down, gm.noTxDown
+	}
+	sc.noTransaction = sc.dbFunc != nil
+	return sc
+}
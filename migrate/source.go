@@ -0,0 +1,122 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Source of migration files, see Options.Sources.
+type Source struct {
+	// FS with the migration files.
+	FS fs.FS
+	// Dir in FS with the migration files, defaults to the root of FS.
+	Dir string
+	// Namespace prefixes the versions of the migrations of the source as Namespace/version,
+	// so that sources can use the same versions. It must match ^[\w.-]+$ .
+	// Out-of-order migrations are detected within a namespace, see OutOfOrderError.
+	Namespace string
+}
+
+// file of a migration in a source.
+type file struct {
+	// source is the index of the source in Migrator.sources
+	source int
+	fsys   fs.FS
+	// path of the file in fsys
+	path string
+	// version of the migration, prefixed with the namespace of the source
+	version string
+	up      bool
+}
+
+// newSource with the FS of Dir.
+// It panics on illegal options, like New.
+func newSource(src Source) Source {
+	if src.FS == nil {
+		panic("FS of source " + src.Namespace + " must be set")
+	}
+	if src.Namespace != "" && !versionMatcher.MatchString(src.Namespace) {
+		panic("illegal namespace " + src.Namespace + ", must match " + versionMatcher.String())
+	}
+	if src.Dir != "" && src.Dir != "." {
+		sub, err := fs.Sub(src.FS, src.Dir)
+		if err != nil {
+			panic("illegal dir " + src.Dir + ": " + err.Error())
+		}
+		src.FS = sub
+	}
+	return src
+}
+
+// getFiles of every source, in sub-directories too if Options.Recursive is set.
+func (m *Migrator) getFiles() ([]file, error) {
+	var files []file
+	for i, src := range m.sources {
+		add := func(p string) {
+			name := path.Base(p)
+			if match := upMatcher.FindStringSubmatch(name); match != nil {
+				files = append(files, file{source: i, fsys: src.FS, path: p, version: namespaced(src.Namespace, match[1]), up: true})
+			} else if match := downMatcher.FindStringSubmatch(name); match != nil {
+				files = append(files, file{source: i, fsys: src.FS, path: p, version: namespaced(src.Namespace, match[1])})
+			}
+		}
+
+		if !m.recursive {
+			entries, err := fs.ReadDir(src.FS, ".")
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					add(entry.Name())
+				}
+			}
+			continue
+		}
+
+		if err := fs.WalkDir(src.FS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				add(p)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// namespaced version.
+func namespaced(namespace, version string) string {
+	if namespace == "" {
+		return version
+	}
+	return namespace + "/" + version
+}
+
+// splitNamespace of version.
+func splitNamespace(version string) (namespace, rest string) {
+	if namespace, rest, ok := strings.Cut(version, "/"); ok {
+		return namespace, rest
+	}
+	return "", version
+}
+
+// checkDownFiles of migrations, which must all have a down file or function with Options.RequireDown.
+func checkDownFiles(migrations []migration) error {
+	var missing []string
+	for _, mig := range migrations {
+		if mig.canApply() && !mig.canRevert() {
+			missing = append(missing, mig.version)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("error finding down migrations for versions %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
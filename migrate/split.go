@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"strings"
+)
+
+// statement of a migration file, starting at line.
+type statement struct {
+	query string
+	line  int
+}
+
+// splitStatements of a migration file at the semicolons that end them.
+//
+// Semicolons in quoted strings and identifiers, comments, dollar-quoted strings and BEGIN...END blocks,
+// such as trigger bodies, do not end statements. Backslashes escape quotes if backslashEscapes is set,
+// which is the default on MySQL, and in PostgreSQL E'...' strings. Statements that only contain comments are dropped.
+func splitStatements(content string, backslashEscapes bool) []statement {
+	s := splitter{content: content, backslashEscapes: backslashEscapes, line: 1}
+	return s.split()
+}
+
+type splitter struct {
+	content          string
+	backslashEscapes bool
+	statements       []statement
+	// start of the current statement
+	start int
+	// line of the first code in the current statement, or 0 if it has no code yet
+	startLine int
+	line      int
+	// depth of BEGIN...END and CASE...END blocks
+	depth int
+}
+
+func (s *splitter) split() []statement {
+	i := 0
+	for i < len(s.content) {
+		c := s.content[i]
+		switch {
+		case c == '\n':
+			s.line++
+			i++
+		case c == '-' && strings.HasPrefix(s.content[i:], "--"):
+			end := strings.IndexByte(s.content[i:], '\n')
+			if end < 0 {
+				end = len(s.content) - i
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(s.content[i:], "/*"):
+			end := strings.Index(s.content[i+2:], "*/")
+			if end < 0 {
+				end = len(s.content) - i - 2
+			} else {
+				end += 2
+			}
+			i = s.skip(i, i+2+end)
+		case c == '\'' || c == '"' || c == '`':
+			s.code()
+			i = s.skip(i, s.quoteEnd(i, c, s.backslashEscapes && c != '`'))
+		case c == '$':
+			s.code()
+			if tag, ok := s.dollarTag(i); ok {
+				end := strings.Index(s.content[i+len(tag):], tag)
+				if end < 0 {
+					end = len(s.content) - i - len(tag)
+				} else {
+					end += len(tag)
+				}
+				i = s.skip(i, i+len(tag)+end)
+			} else {
+				i++
+			}
+		case isWordStart(c):
+			s.code()
+			end := wordEnd(s.content, i)
+			if end == i+1 && (c == 'E' || c == 'e') && end < len(s.content) && s.content[end] == '\'' {
+				// PostgreSQL string with C-style escapes
+				i = s.skip(i, s.quoteEnd(end, '\'', true))
+				continue
+			}
+			i = s.keyword(strings.ToUpper(s.content[i:end]), end)
+		case c == ';' && s.depth == 0:
+			s.flush(i)
+			i++
+			s.start = i
+		default:
+			if c != ' ' && c != '\t' && c != '\r' && c != ';' {
+				s.code()
+			}
+			i++
+		}
+	}
+	s.flush(len(s.content))
+	return s.statements
+}
+
+// code marks the current statement as having code.
+func (s *splitter) code() {
+	if s.startLine == 0 {
+		s.startLine = s.line
+	}
+}
+
+// flush the current statement, which ends at end.
+func (s *splitter) flush(end int) {
+	if s.startLine != 0 {
+		s.statements = append(s.statements, statement{query: strings.TrimSpace(s.content[s.start:end]), line: s.startLine})
+	}
+	s.startLine = 0
+}
+
+// skip from i to end, counting lines, and return end.
+func (s *splitter) skip(i, end int) int {
+	s.line += strings.Count(s.content[i:end], "\n")
+	return end
+}
+
+// quoteEnd returns the index after the quote q that closes the quote at i.
+// Doubled quotes are escaped quotes, and so are quotes after a backslash if backslashEscapes is set.
+func (s *splitter) quoteEnd(i int, q byte, backslashEscapes bool) int {
+	for j := i + 1; j < len(s.content); j++ {
+		switch s.content[j] {
+		case '\\':
+			if backslashEscapes {
+				j++
+			}
+		case q:
+			if j+1 < len(s.content) && s.content[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s.content)
+}
+
+// dollarTag returns the tag of a dollar-quoted string starting at i, such as $$ or $body$.
+// Positional parameters such as $1 are not tags.
+func (s *splitter) dollarTag(i int) (string, bool) {
+	for j := i + 1; j < len(s.content); j++ {
+		c := s.content[j]
+		switch {
+		case c == '$':
+			return s.content[i : j+1], true
+		case isWordStart(c) || (j > i+1 && c >= '0' && c <= '9'):
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// keyword tracks BEGIN...END blocks, where word ends at end, and returns the index to continue at.
+func (s *splitter) keyword(word string, end int) int {
+	switch word {
+	case "BEGIN":
+		// BEGIN on its own starts a transaction, not a block
+		switch s.nextWord(end) {
+		case "", ";", "TRANSACTION", "TRAN", "WORK", "DEFERRED", "IMMEDIATE", "EXCLUSIVE", "ISOLATION", "READ":
+		default:
+			s.depth++
+		}
+	case "CASE":
+		s.depth++
+	case "END":
+		if s.depth == 0 {
+			return end
+		}
+		// ends of MySQL compound statements are not counted, as their starts are not either
+		switch s.nextWord(end) {
+		case "IF", "LOOP", "WHILE", "REPEAT":
+		case "CASE":
+			// END CASE closes a MySQL CASE statement, its CASE does not open another block
+			s.depth--
+			return s.skipWord(end)
+		default:
+			s.depth--
+		}
+	}
+	return end
+}
+
+// skipWord returns the index after the word following i.
+func (s *splitter) skipWord(i int) int {
+	for i < len(s.content) && strings.IndexByte(" \t\r\n", s.content[i]) >= 0 {
+		if s.content[i] == '\n' {
+			s.line++
+		}
+		i++
+	}
+	return wordEnd(s.content, i)
+}
+
+// nextWord after i in upper case, or the next character if it is not a word, or empty at the end.
+func (s *splitter) nextWord(i int) string {
+	for i < len(s.content) && strings.IndexByte(" \t\r\n", s.content[i]) >= 0 {
+		i++
+	}
+	if i == len(s.content) {
+		return ""
+	}
+	if !isWordStart(s.content[i]) {
+		return s.content[i : i+1]
+	}
+	return strings.ToUpper(s.content[i:wordEnd(s.content, i)])
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// wordEnd returns the index after the word starting at i.
+func wordEnd(content string, i int) int {
+	for i < len(content) && (isWordStart(content[i]) || (content[i] >= '0' && content[i] <= '9')) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		content          string
+		backslashEscapes bool
+		want             []statement
+	}{
+		"semicolons": {
+			content: "CREATE TABLE a (x int);\n\nINSERT INTO a VALUES (1);",
+			want:    []statement{{"CREATE TABLE a (x int)", 1}, {"INSERT INTO a VALUES (1)", 3}},
+		},
+		"quotes": {
+			content: "INSERT INTO a VALUES ('a;b', \"c;d\", `e;f`, 'it''s;');\nSELECT 2;",
+			want:    []statement{{"INSERT INTO a VALUES ('a;b', \"c;d\", `e;f`, 'it''s;')", 1}, {"SELECT 2", 2}},
+		},
+		"comments": {
+			content: "-- only a comment;\n/* block;\ncomment */ SELECT 1; -- trailing\n;",
+			want:    []statement{{"-- only a comment;\n/* block;\ncomment */ SELECT 1", 3}},
+		},
+		"dollar quotes": {
+			content: "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;\nSELECT $1;",
+			want:    []statement{{"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql", 1}, {"SELECT $1", 2}},
+		},
+		"trigger": {
+			content: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO b VALUES (CASE WHEN new.x THEN 1 ELSE 2 END);\nEND;\nSELECT 2;",
+			want:    []statement{{"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO b VALUES (CASE WHEN new.x THEN 1 ELSE 2 END);\nEND", 1}, {"SELECT 2", 4}},
+		},
+		"transaction": {
+			content: "BEGIN;\nSELECT 1;\nCOMMIT;",
+			want:    []statement{{"BEGIN", 1}, {"SELECT 1", 2}, {"COMMIT", 3}},
+		},
+		"end if": {
+			content: "CREATE PROCEDURE p() BEGIN IF x THEN SELECT 1; END IF; END;\nSELECT 2;",
+			want:    []statement{{"CREATE PROCEDURE p() BEGIN IF x THEN SELECT 1; END IF; END", 1}, {"SELECT 2", 2}},
+		},
+		"end case": {
+			content: "CREATE PROCEDURE p() BEGIN CASE x WHEN 1 THEN SELECT 1; END CASE; END;\nSELECT 2;",
+			want:    []statement{{"CREATE PROCEDURE p() BEGIN CASE x WHEN 1 THEN SELECT 1; END CASE; END", 1}, {"SELECT 2", 2}},
+		},
+		"end case over lines": {
+			content: "CREATE PROCEDURE p() BEGIN CASE x WHEN 1 THEN SELECT 1; END\nCASE; END;\nSELECT 2;",
+			want:    []statement{{"CREATE PROCEDURE p() BEGIN CASE x WHEN 1 THEN SELECT 1; END\nCASE; END", 1}, {"SELECT 2", 3}},
+		},
+		"backslash without escapes": {
+			content: "SELECT 'a\\'; SELECT 2;",
+			want:    []statement{{"SELECT 'a\\'", 1}, {"SELECT 2", 1}},
+		},
+		"backslash escapes": {
+			content:          "SELECT 'a\\'; b'; SELECT 2;",
+			backslashEscapes: true,
+			want:             []statement{{"SELECT 'a\\'; b'", 1}, {"SELECT 2", 1}},
+		},
+		"escape string": {
+			content: "SELECT E'a\\'; b', e'\\\\';\nSELECT 2;",
+			want:    []statement{{"SELECT E'a\\'; b', e'\\\\'", 1}, {"SELECT 2", 2}},
+		},
+		"escape identifier": {
+			content: "SELECT e FROM t WHERE e = 'a\\'; SELECT 2;",
+			want:    []statement{{"SELECT e FROM t WHERE e = 'a\\'", 1}, {"SELECT 2", 1}},
+		},
+		"empty": {
+			content: " ;\n-- nothing\n;",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, splitStatements(tt.content, tt.backslashEscapes))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// StatusKind of a migration.
+type StatusKind string
+
+const (
+	// StatusApplied means the migration has been applied.
+	StatusApplied StatusKind = "applied"
+	// StatusPending means the migration has not been applied yet.
+	StatusPending StatusKind = "pending"
+	// StatusMissingFile means the migration has been applied, but its file does not exist anymore.
+	StatusMissingFile StatusKind = "missing file"
+)
+
+// MigrationStatus is the status of a single migration.
+type MigrationStatus struct {
+	Version string
+	Kind    StatusKind
+	// AppliedAt is the zero time for pending migrations, and for migrations applied before the history table existed.
+	AppliedAt time.Time
+}
+
+// Status of every known migration, which are the migration files and the applied migrations, in version order.
+func (m *Migrator) Status(ctx context.Context) (statuses []MigrationStatus, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error getting migration status: %w", err)
+		}
+	}()
+
+	s, err := m.getState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := m.getHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[string]HistoryEntry{}
+	for _, entry := range appliedEntries(history) {
+		applied[entry.Version] = entry
+	}
+
+	for _, mig := range s.migrations {
+		status := MigrationStatus{Version: mig.version, Kind: StatusPending}
+		if s.applied[mig.version] {
+			// migrations applied before the history table existed have no time
+			status.Kind = StatusApplied
+			status.AppliedAt = applied[mig.version].AppliedAt
+			delete(applied, mig.version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, entry := range applied {
+		statuses = append(statuses, MigrationStatus{Version: entry.Version, Kind: StatusMissingFile, AppliedAt: entry.AppliedAt})
+	}
+
+	slices.SortStableFunc(statuses, func(a, b MigrationStatus) int {
+		return m.compareVersions(a.Version, b.Version)
+	})
+	return statuses, nil
+}
@@ -0,0 +1,114 @@
+package migrate
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDuplicateVersion is returned when two migration files parse to the same version key.
+var ErrDuplicateVersion = errors.New("duplicate migration version")
+
+// VersionKey is the key migrations are ordered by, see VersionParser.
+type VersionKey struct {
+	numbers []uint64
+	text    string
+}
+
+// Compare k to other, returning -1, 0 or +1.
+// Numeric parts are compared first, then the text part.
+func (k VersionKey) Compare(other VersionKey) int {
+	if c := slices.Compare(k.numbers, other.numbers); c != 0 {
+		return c
+	}
+	return cmp.Compare(k.text, other.text)
+}
+
+// String returns the key in a human readable form.
+func (k VersionKey) String() string {
+	parts := make([]string, 0, len(k.numbers)+1)
+	for _, n := range k.numbers {
+		parts = append(parts, strconv.FormatUint(n, 10))
+	}
+	if k.text != "" {
+		parts = append(parts, k.text)
+	}
+	return strings.Join(parts, ".")
+}
+
+// VersionParser parses the version of a migration file, which is the file name without
+// the .up.sql or .down.sql suffix, into the key migrations are ordered by.
+// Two files with the same key are reported as ErrDuplicateVersion.
+type VersionParser func(version string) (VersionKey, error)
+
+// LexicalVersions orders versions as plain strings, so 10-foo comes before 2-bar. It is the default.
+func LexicalVersions(version string) (VersionKey, error) {
+	return VersionKey{text: version}, nil
+}
+
+// IntegerVersions orders versions by their integer prefix, such as 1 in 1-accounts.
+func IntegerVersions(version string) (VersionKey, error) {
+	digits, _, err := splitPrefix(version, "integer")
+	if err != nil {
+		return VersionKey{}, err
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return VersionKey{}, fmt.Errorf("invalid integer version %v: %w", version, err)
+	}
+	return VersionKey{numbers: []uint64{n}}, nil
+}
+
+// TimestampVersions orders versions by their timestamp prefix in the form YYYYMMDDhhmmss,
+// such as 20240101120000_accounts.
+func TimestampVersions(version string) (VersionKey, error) {
+	digits, _, err := splitPrefix(version, "timestamp")
+	if err != nil {
+		return VersionKey{}, err
+	}
+	t, err := time.Parse("20060102150405", digits)
+	if err != nil {
+		return VersionKey{}, fmt.Errorf("invalid timestamp version %v: %w", version, err)
+	}
+	return VersionKey{numbers: []uint64{uint64(t.Unix())}}, nil
+}
+
+// SemverVersions orders versions by their MAJOR[.MINOR[.PATCH]] prefix with an optional v,
+// such as v1.2.0-accounts. Missing parts are zero.
+func SemverVersions(version string) (VersionKey, error) {
+	rest := strings.TrimPrefix(version, "v")
+	numbers := make([]uint64, 3)
+	for i := range numbers {
+		digits, next, err := splitPrefix(rest, "semver")
+		if err != nil {
+			return VersionKey{}, fmt.Errorf("invalid semver version %v: %w", version, err)
+		}
+		if numbers[i], err = strconv.ParseUint(digits, 10, 64); err != nil {
+			return VersionKey{}, fmt.Errorf("invalid semver version %v: %w", version, err)
+		}
+		if !strings.HasPrefix(next, ".") {
+			break
+		}
+		rest = next[1:]
+	}
+	return VersionKey{numbers: numbers}, nil
+}
+
+// splitPrefix of digits from version, which must be followed by the end, a dot, or a - or _ separator.
+func splitPrefix(version, kind string) (digits, rest string, err error) {
+	i := 0
+	for i < len(version) && version[i] >= '0' && version[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return "", "", fmt.Errorf("version %v has no %v prefix", version, kind)
+	}
+	if i < len(version) && !strings.ContainsRune("._-", rune(version[i])) {
+		return "", "", fmt.Errorf("version %v has no separator after its %v prefix", version, kind)
+	}
+	return version[:i], version[i:], nil
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionParsers(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		parser  VersionParser
+		version string
+		want    string
+		wantErr bool
+	}{
+		"lexical":                 {parser: LexicalVersions, version: "1-accounts", want: "1-accounts"},
+		"integer":                 {parser: IntegerVersions, version: "42-accounts", want: "42"},
+		"integer only":            {parser: IntegerVersions, version: "42", want: "42"},
+		"integer underscore":      {parser: IntegerVersions, version: "007_accounts", want: "7"},
+		"integer no prefix":       {parser: IntegerVersions, version: "accounts", wantErr: true},
+		"integer no separator":    {parser: IntegerVersions, version: "1accounts", wantErr: true},
+		"integer overflow":        {parser: IntegerVersions, version: "99999999999999999999-accounts", wantErr: true},
+		"timestamp":               {parser: TimestampVersions, version: "20240101120000_accounts", want: "1704110400"},
+		"timestamp invalid":       {parser: TimestampVersions, version: "20241301120000_accounts", wantErr: true},
+		"timestamp short":         {parser: TimestampVersions, version: "2024_accounts", wantErr: true},
+		"semver":                  {parser: SemverVersions, version: "v1.2.3-accounts", want: "1.2.3"},
+		"semver without v":        {parser: SemverVersions, version: "1.2.3_accounts", want: "1.2.3"},
+		"semver missing parts":    {parser: SemverVersions, version: "v2-accounts", want: "2.0.0"},
+		"semver minor":            {parser: SemverVersions, version: "v2.1", want: "2.1.0"},
+		"semver no prefix":        {parser: SemverVersions, version: "accounts", wantErr: true},
+		"semver invalid part":     {parser: SemverVersions, version: "v1.x", wantErr: true},
+		"semver no separator":     {parser: SemverVersions, version: "v1.2beta", wantErr: true},
+		"semver trailing numbers": {parser: SemverVersions, version: "v1.2.3.4", want: "1.2.3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			key, err := tt.parser(tt.version)
+			if tt.wantErr {
+				r.Error(err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tt.want, key.String())
+		})
+	}
+}
+
+func TestVersionKeyCompare(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		parser VersionParser
+		a, b   string
+		want   int
+	}{
+		"lexical":          {LexicalVersions, "10-a", "2-b", -1},
+		"integer":          {IntegerVersions, "10-a", "2-b", 1},
+		"integer equal":    {IntegerVersions, "01-a", "1-b", 0},
+		"timestamp":        {TimestampVersions, "20240101120000_a", "20231231235959_b", 1},
+		"semver":           {SemverVersions, "v1.10.0", "v1.9.0", 1},
+		"semver missing":   {SemverVersions, "v1", "v1.0.0-a", 0},
+		"semver less":      {SemverVersions, "v0.9.9", "v1", -1},
+		"lexical equal":    {LexicalVersions, "a", "a", 0},
+		"lexical prefixed": {LexicalVersions, "a", "ab", -1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			a, err := tt.parser(tt.a)
+			r.NoError(err)
+			b, err := tt.parser(tt.b)
+			r.NoError(err)
+			r.Equal(tt.want, a.Compare(b))
+			r.Equal(-tt.want, b.Compare(a))
+		})
+	}
+}
@@ -0,0 +1,215 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type batchQueue interface {
+	Push(*P) error
+	PushBatch([]*P) (int, error)
+	PopBatch([]*P, int) ([]*P, error)
+	PopBatchTimeout([]*P, int, time.Duration) ([]*P, error)
+	Length() int
+	Close()
+	Drain() []*P
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+
+	queues := map[string]func(size uint64) batchQueue{
+		"circular": func(size uint64) batchQueue {
+			return NewCircular[P, *P](size)
+		},
+		"lock-free": func(size uint64) batchQueue {
+			return NewLockFree[P, *P](size)
+		},
+	}
+
+	packets := func(from, to int) []*P {
+		ps := make([]*P, 0, to-from)
+		for i := from; i < to; i++ {
+			ps = append(ps, &P{Int: i})
+		}
+		return ps
+	}
+
+	for name, newQueue := range queues {
+		newQueue := newQueue
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("success", func(t *testing.T) {
+				q := newQueue(8)
+				n, err := q.PushBatch(packets(0, 5))
+				require.NoError(t, err)
+				assert.Equal(t, 5, n)
+				assert.Equal(t, 5, q.Length())
+
+				dst := make([]*P, 0, 8)
+				dst, err = q.PopBatch(dst, 3)
+				require.NoError(t, err)
+				require.Len(t, dst, 3)
+				dst, err = q.PopBatch(dst, 3)
+				require.NoError(t, err)
+				require.Len(t, dst, 5)
+				for i, p := range dst {
+					assert.Equal(t, i, p.Int)
+				}
+				assert.Equal(t, 0, q.Length())
+			})
+
+			t.Run("push waits for space", func(t *testing.T) {
+				q := newQueue(4)
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					n, err := q.PushBatch(packets(0, 10))
+					assert.NoError(t, err)
+					assert.Equal(t, 10, n)
+				}()
+
+				var received []*P
+				for len(received) < 10 {
+					var err error
+					received, err = q.PopBatch(received, 3)
+					require.NoError(t, err)
+				}
+				<-done
+				for i, p := range received {
+					assert.Equal(t, i, p.Int)
+				}
+			})
+
+			t.Run("pop waits for one", func(t *testing.T) {
+				q := newQueue(4)
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					_ = q.Push(&P{Int: 1})
+				}()
+				dst, err := q.PopBatch(nil, 4)
+				require.NoError(t, err)
+				require.Len(t, dst, 1)
+				assert.Equal(t, 1, dst[0].Int)
+			})
+
+			t.Run("timeout flushes partial batch", func(t *testing.T) {
+				q := newQueue(8)
+				_, err := q.PopBatchTimeout(nil, 4, 10*time.Millisecond)
+				assert.ErrorIs(t, err, TimeoutError)
+
+				_, err = q.PushBatch(packets(0, 2))
+				require.NoError(t, err)
+				start := time.Now()
+				dst, err := q.PopBatchTimeout(nil, 4, 20*time.Millisecond)
+				require.NoError(t, err)
+				assert.Len(t, dst, 2)
+				assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+				_, err = q.PushBatch(packets(0, 6))
+				require.NoError(t, err)
+				dst, err = q.PopBatchTimeout(nil, 4, time.Hour)
+				require.NoError(t, err)
+				assert.Len(t, dst, 4)
+			})
+
+			t.Run("closed", func(t *testing.T) {
+				q := newQueue(2)
+				errCh := make(chan error, 1)
+				var n int
+				go func() {
+					var err error
+					n, err = q.PushBatch(packets(0, 5))
+					errCh <- err
+				}()
+				time.Sleep(10 * time.Millisecond)
+				q.Close()
+				select {
+				case err := <-errCh:
+					assert.ErrorIs(t, err, Closed)
+				case <-time.After(time.Second):
+					t.Fatal("PushBatch did not return after Close")
+				}
+
+				_, err := q.PopBatch(nil, 2)
+				assert.ErrorIs(t, err, Closed)
+				_, err = q.PopBatchTimeout(nil, 2, time.Millisecond)
+				assert.ErrorIs(t, err, Closed)
+				assert.Less(t, n, 5)
+				assert.Len(t, q.Drain(), n)
+			})
+
+			t.Run("concurrent", func(t *testing.T) {
+				t.Parallel()
+				const producers, consumers, batches, batchSize = 4, 4, 500, 7
+				q := newQueue(32)
+				received := make([][]*P, consumers)
+
+				var wg sync.WaitGroup
+				for i := 0; i < consumers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						var err error
+						for err == nil {
+							received[i], err = q.PopBatchTimeout(received[i], 5, time.Millisecond)
+							if err == TimeoutError {
+								err = nil
+							}
+						}
+						assert.ErrorIs(t, err, Closed)
+					}(i)
+				}
+				var producersWG sync.WaitGroup
+				for i := 0; i < producers; i++ {
+					producersWG.Add(1)
+					go func(i int) {
+						defer producersWG.Done()
+						for j := 0; j < batches; j++ {
+							from := (i*batches + j) * batchSize
+							_, err := q.PushBatch(packets(from, from+batchSize))
+							if !assert.NoError(t, err) {
+								return
+							}
+						}
+					}(i)
+				}
+				producersWG.Wait()
+				for q.Length() > 0 {
+					time.Sleep(time.Millisecond)
+				}
+				q.Close()
+				wg.Wait()
+
+				seen := make([]bool, producers*batches*batchSize)
+				for _, items := range received {
+					for _, p := range items {
+						require.False(t, seen[p.Int], "item %d", p.Int)
+						seen[p.Int] = true
+					}
+				}
+				for i, ok := range seen {
+					require.True(t, ok, "item %d", i)
+				}
+			})
+		})
+	}
+}
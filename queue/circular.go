@@ -14,7 +14,9 @@
 package queue
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 // Circular is a circular sized FIFO queue that uses
@@ -24,6 +26,17 @@ import (
 // it is a blocking queue and will block the caller
 // if the queue is full or if it is empty.
 type Circular[T any, P Pointer[T]] struct {
+	circular[P]
+}
+
+// CircularValue is a Circular queue that stores
+// the elements by value instead of by pointer.
+type CircularValue[T any] struct {
+	circular[T]
+}
+
+// circular implements Circular and CircularValue for elements of type T.
+type circular[T any] struct {
 	_padding0 [8]uint64 //nolint:structcheck,unused
 	head      uint64
 	_padding1 [8]uint64 //nolint:structcheck,unused
@@ -39,12 +52,25 @@ type Circular[T any, P Pointer[T]] struct {
 	_padding6 [8]uint64 //nolint:structcheck,unused
 	notFull   *sync.Cond
 	_padding7 [8]uint64 //nolint:structcheck,unused
-	nodes     []P
+	nodes     []T
 }
 
 // NewCircular creates a new circular queue with the given size.
 func NewCircular[T any, P Pointer[T]](maxSize uint64) *Circular[T, P] {
 	q := new(Circular[T, P])
+	q.init(maxSize)
+	return q
+}
+
+// NewCircularValue creates a new circular queue of values with the given size.
+func NewCircularValue[T any](maxSize uint64) *CircularValue[T] {
+	q := new(CircularValue[T])
+	q.init(maxSize)
+	return q
+}
+
+// init is an internal function used to initialize the queue with the given size.
+func (q *circular[T]) init(maxSize uint64) {
 	q.lock = new(sync.Mutex)
 	q.notFull = sync.NewCond(q.lock)
 	q.notEmpty = sync.NewCond(q.lock)
@@ -58,12 +84,11 @@ func NewCircular[T any, P Pointer[T]](maxSize uint64) *Circular[T, P] {
 		q.maxSize = round(maxSize)
 	}
 
-	q.nodes = make([]P, q.maxSize)
-	return q
+	q.nodes = make([]T, q.maxSize)
 }
 
 // IsEmpty returns true if the queue is empty.
-func (q *Circular[T, P]) IsEmpty() (empty bool) {
+func (q *circular[T]) IsEmpty() (empty bool) {
 	q.lock.Lock()
 	empty = q.isEmpty()
 	q.lock.Unlock()
@@ -72,12 +97,12 @@ func (q *Circular[T, P]) IsEmpty() (empty bool) {
 
 // isEmpty is an internal function used to check if the
 // queue is empty.
-func (q *Circular[T, P]) isEmpty() bool {
+func (q *circular[T]) isEmpty() bool {
 	return q.head == q.tail
 }
 
 // IsFull returns true if the queue is full.
-func (q *Circular[T, P]) IsFull() (full bool) {
+func (q *circular[T]) IsFull() (full bool) {
 	q.lock.Lock()
 	full = q.isFull()
 	q.lock.Unlock()
@@ -86,14 +111,14 @@ func (q *Circular[T, P]) IsFull() (full bool) {
 
 // isFull is an internal function used to check if the
 // queue is full.
-func (q *Circular[T, P]) isFull() bool {
+func (q *circular[T]) isFull() bool {
 	return q.head == (q.tail+1)%q.maxSize
 }
 
 // IsClosed returns true if the queue is Closed
 //
 // The Drain method can be used to drain the queue after it is closed.
-func (q *Circular[T, P]) IsClosed() (closed bool) {
+func (q *circular[T]) IsClosed() (closed bool) {
 	q.lock.Lock()
 	closed = q.isClosed()
 	q.lock.Unlock()
@@ -102,12 +127,12 @@ func (q *Circular[T, P]) IsClosed() (closed bool) {
 
 // isClosed is an internal function used to check if the
 // queue is closed.
-func (q *Circular[T, P]) isClosed() bool {
+func (q *circular[T]) isClosed() bool {
 	return q.closed
 }
 
 // Length returns the number of elements in the queue.
-func (q *Circular[T, P]) Length() (size int) {
+func (q *circular[T]) Length() (size int) {
 	q.lock.Lock()
 	size = q.length()
 	q.lock.Unlock()
@@ -115,7 +140,7 @@ func (q *Circular[T, P]) Length() (size int) {
 }
 
 // length is an internal function used to get the number of elements in the queue.
-func (q *Circular[T, P]) length() int {
+func (q *circular[T]) length() int {
 	if q.tail < q.head {
 		return int(q.maxSize - q.head + q.tail)
 	}
@@ -125,7 +150,7 @@ func (q *Circular[T, P]) length() int {
 // Close closes the queue permanently.
 //
 // The Drain method can be used to drain the queue after it is closed.
-func (q *Circular[T, P]) Close() {
+func (q *circular[T]) Close() {
 	q.lock.Lock()
 	q.closed = true
 	q.notFull.Broadcast()
@@ -134,7 +159,7 @@ func (q *Circular[T, P]) Close() {
 }
 
 // Push adds an element to the queue.
-func (q *Circular[T, P]) Push(p P) error {
+func (q *circular[T]) Push(p T) error {
 	q.lock.Lock()
 LOOP:
 	if q.isClosed() {
@@ -154,12 +179,12 @@ LOOP:
 }
 
 // Pop removes an element from the queue.
-func (q *Circular[T, P]) Pop() (p P, err error) {
+func (q *circular[T]) Pop() (p T, err error) {
 	q.lock.Lock()
 LOOP:
 	if q.isClosed() {
 		q.lock.Unlock()
-		return nil, Closed
+		return p, Closed
 	}
 	if q.isEmpty() {
 		q.notEmpty.Wait()
@@ -173,20 +198,303 @@ LOOP:
 	return
 }
 
+// PushContext adds an element to the queue, waiting for space until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *circular[T]) PushContext(ctx context.Context, p T) error {
+	if pushed, err := q.tryPush(p); pushed || err != nil {
+		return err
+	}
+	return q.pushContext(ctx, p)
+}
+
+// PushTimeout adds an element to the queue, waiting for space for at most d.
+// TimeoutError is returned if there is no space in time.
+func (q *circular[T]) PushTimeout(p T, d time.Duration) error {
+	if pushed, err := q.tryPush(p); pushed || err != nil {
+		return err
+	}
+	_, err := withTimeout(d, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, q.pushContext(ctx, p)
+	})
+	return err
+}
+
+// tryPush is an internal function used to add an element to the
+// queue without waiting, returning false if the queue is full.
+func (q *circular[T]) tryPush(p T) (bool, error) {
+	q.lock.Lock()
+	if q.isClosed() {
+		q.lock.Unlock()
+		return false, Closed
+	}
+	if q.isFull() {
+		q.lock.Unlock()
+		return false, nil
+	}
+
+	q.nodes[q.tail] = p
+	q.tail = (q.tail + 1) % q.maxSize
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return true, nil
+}
+
+// pushContext is an internal function used to wait for space in the queue
+// until ctx is done, which wakes up the waiters.
+func (q *circular[T]) pushContext(ctx context.Context, p T) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		q.notFull.Broadcast()
+		q.lock.Unlock()
+	})
+	defer stop()
+
+	q.lock.Lock()
+LOOP:
+	if q.isClosed() {
+		q.lock.Unlock()
+		return Closed
+	}
+	if err := ctx.Err(); err != nil {
+		// pass on a wake up that was meant for another waiter
+		if !q.isFull() {
+			q.notFull.Signal()
+		}
+		q.lock.Unlock()
+		return err
+	}
+	if q.isFull() {
+		q.notFull.Wait()
+		goto LOOP
+	}
+
+	q.nodes[q.tail] = p
+	q.tail = (q.tail + 1) % q.maxSize
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return nil
+}
+
+// PopContext removes an element from the queue, waiting for one until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *circular[T]) PopContext(ctx context.Context) (T, error) {
+	if p, popped, err := q.tryPop(); popped || err != nil {
+		return p, err
+	}
+	return q.popContext(ctx)
+}
+
+// PopTimeout removes an element from the queue, waiting for one for at most d.
+// TimeoutError is returned if there is no element in time.
+func (q *circular[T]) PopTimeout(d time.Duration) (T, error) {
+	if p, popped, err := q.tryPop(); popped || err != nil {
+		return p, err
+	}
+	return withTimeout(d, q.popContext)
+}
+
+// tryPop is an internal function used to remove an element from the
+// queue without waiting, returning false if the queue is empty.
+func (q *circular[T]) tryPop() (p T, popped bool, err error) {
+	q.lock.Lock()
+	if q.isClosed() {
+		q.lock.Unlock()
+		return p, false, Closed
+	}
+	if q.isEmpty() {
+		q.lock.Unlock()
+		return p, false, nil
+	}
+
+	p = q.nodes[q.head]
+	q.head = (q.head + 1) % q.maxSize
+	q.notFull.Signal()
+	q.lock.Unlock()
+	return p, true, nil
+}
+
+// popContext is an internal function used to wait for an element in the queue
+// until ctx is done, which wakes up the waiters.
+func (q *circular[T]) popContext(ctx context.Context) (p T, err error) {
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		q.notEmpty.Broadcast()
+		q.lock.Unlock()
+	})
+	defer stop()
+
+	q.lock.Lock()
+LOOP:
+	if q.isClosed() {
+		q.lock.Unlock()
+		return p, Closed
+	}
+	if err = ctx.Err(); err != nil {
+		// pass on a wake up that was meant for another waiter
+		if !q.isEmpty() {
+			q.notEmpty.Signal()
+		}
+		q.lock.Unlock()
+		return p, err
+	}
+	if q.isEmpty() {
+		q.notEmpty.Wait()
+		goto LOOP
+	}
+
+	p = q.nodes[q.head]
+	q.head = (q.head + 1) % q.maxSize
+	q.notFull.Signal()
+	q.lock.Unlock()
+	return
+}
+
+// PushBatch adds the elements of ps to the queue in order, adding as many
+// as there is space for at once and waiting for space for the rest.
+//
+// It returns the number of elements added, which is less than len(ps)
+// only if the queue is closed.
+func (q *circular[T]) PushBatch(ps []T) (n int, err error) {
+	if len(ps) == 0 {
+		return 0, nil
+	}
+	q.lock.Lock()
+LOOP:
+	if q.isClosed() {
+		q.lock.Unlock()
+		return n, Closed
+	}
+	if q.isFull() {
+		q.notFull.Wait()
+		goto LOOP
+	}
+
+	pushed := 0
+	for ; n < len(ps) && !q.isFull(); n++ {
+		q.nodes[q.tail] = ps[n]
+		q.tail = (q.tail + 1) % q.maxSize
+		pushed++
+	}
+	if pushed == 1 {
+		q.notEmpty.Signal()
+	} else {
+		q.notEmpty.Broadcast()
+	}
+	if n < len(ps) {
+		q.notFull.Wait()
+		goto LOOP
+	}
+	q.lock.Unlock()
+	return n, nil
+}
+
+// PopBatch removes up to max elements from the queue at once and appends them
+// to dst, waiting until there is at least one.
+func (q *circular[T]) PopBatch(dst []T, max int) ([]T, error) {
+	if max <= 0 {
+		return dst, nil
+	}
+	q.lock.Lock()
+LOOP:
+	if q.isClosed() {
+		q.lock.Unlock()
+		return dst, Closed
+	}
+	if q.isEmpty() {
+		q.notEmpty.Wait()
+		goto LOOP
+	}
+
+	dst = q.popBatch(dst, max)
+	q.lock.Unlock()
+	return dst, nil
+}
+
+// PopBatchTimeout removes up to max elements from the queue and appends them to dst,
+// waiting for at most d for there to be max elements. The elements removed until then are
+// returned as a partial batch, and TimeoutError only if there are none.
+//
+// If the queue is closed while waiting, the elements removed until then are returned with Closed.
+func (q *circular[T]) PopBatchTimeout(dst []T, max int, d time.Duration) ([]T, error) {
+	if max <= 0 {
+		return dst, nil
+	}
+	q.lock.Lock()
+	if q.isClosed() {
+		q.lock.Unlock()
+		return dst, Closed
+	}
+	if q.length() >= max {
+		dst = q.popBatch(dst, max)
+		q.lock.Unlock()
+		return dst, nil
+	}
+	q.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		q.notEmpty.Broadcast()
+		q.lock.Unlock()
+	})
+	defer stop()
+
+	start := len(dst)
+	q.lock.Lock()
+LOOP:
+	if q.isClosed() {
+		q.lock.Unlock()
+		return dst, Closed
+	}
+	if !q.isEmpty() {
+		dst = q.popBatch(dst, max-(len(dst)-start))
+	}
+	if len(dst)-start == max {
+		q.lock.Unlock()
+		return dst, nil
+	}
+	if ctx.Err() != nil {
+		q.lock.Unlock()
+		if len(dst) == start {
+			return dst, TimeoutError
+		}
+		return dst, nil
+	}
+	q.notEmpty.Wait()
+	goto LOOP
+}
+
+// popBatch is an internal function used to remove up to max elements
+// from the queue and append them to dst.
+func (q *circular[T]) popBatch(dst []T, max int) []T {
+	n := min(max, q.length())
+	for i := 0; i < n; i++ {
+		dst = append(dst, q.nodes[q.head])
+		q.head = (q.head + 1) % q.maxSize
+	}
+	if n == 1 {
+		q.notFull.Signal()
+	} else {
+		q.notFull.Broadcast()
+	}
+	return dst
+}
+
 // Drain removes all elements from the queue.
 // and returns them in a slice.
 //
 // This function should only be called after the queue is closed.
-func (q *Circular[T, P]) Drain() (values []P) {
+func (q *circular[T]) Drain() (values []T) {
 	q.lock.Lock()
 	if q.isEmpty() {
 		q.lock.Unlock()
 		return nil
 	}
 	if size := int(q.head) - int(q.tail); size > 0 {
-		values = make([]P, 0, size)
+		values = make([]T, 0, size)
 	} else {
-		values = make([]P, 0, -1*size)
+		values = make([]T, 0, -1*size)
 	}
 	for i := 0; i < cap(values); i++ {
 		values = append(values, q.nodes[q.head])
@@ -0,0 +1,250 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type contextQueue interface {
+	Push(*P) error
+	Pop() (*P, error)
+	PushContext(context.Context, *P) error
+	PopContext(context.Context) (*P, error)
+	PushTimeout(*P, time.Duration) error
+	PopTimeout(time.Duration) (*P, error)
+	Close()
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	queues := map[string]func() contextQueue{
+		"circular": func() contextQueue {
+			return NewCircular[P, *P](1)
+		},
+		"non-blocking": func() contextQueue {
+			return NewNonBlocking[P, *P](1)
+		},
+		"lock-free": func() contextQueue {
+			return NewLockFree[P, *P](1)
+		},
+		"priority": func() contextQueue {
+			return NewPriority[*P](1, func(a, b *P) bool { return false })
+		},
+	}
+
+	for name, newQueue := range queues {
+		newQueue := newQueue
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("success", func(t *testing.T) {
+				q := newQueue()
+				p := new(P)
+				require.NoError(t, q.PushContext(context.Background(), p))
+				actual, err := q.PopContext(context.Background())
+				require.NoError(t, err)
+				assert.Same(t, p, actual)
+
+				require.NoError(t, q.PushTimeout(p, time.Millisecond))
+				actual, err = q.PopTimeout(time.Millisecond)
+				require.NoError(t, err)
+				assert.Same(t, p, actual)
+			})
+
+			t.Run("timeout", func(t *testing.T) {
+				q := newQueue()
+				_, err := q.PopTimeout(10 * time.Millisecond)
+				assert.ErrorIs(t, err, TimeoutError)
+
+				require.NoError(t, q.Push(new(P)))
+				err = q.PushTimeout(new(P), 10*time.Millisecond)
+				assert.ErrorIs(t, err, TimeoutError)
+			})
+
+			t.Run("cancel", func(t *testing.T) {
+				q := newQueue()
+				ctx, cancel := context.WithCancel(context.Background())
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := q.PopContext(ctx)
+					errCh <- err
+				}()
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+				select {
+				case err := <-errCh:
+					assert.ErrorIs(t, err, context.Canceled)
+				case <-time.After(time.Second):
+					t.Fatal("PopContext did not return after cancel")
+				}
+
+				require.NoError(t, q.Push(new(P)))
+				ctx, cancel = context.WithCancel(context.Background())
+				go func() {
+					errCh <- q.PushContext(ctx, new(P))
+				}()
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+				select {
+				case err := <-errCh:
+					assert.ErrorIs(t, err, context.Canceled)
+				case <-time.After(time.Second):
+					t.Fatal("PushContext did not return after cancel")
+				}
+			})
+
+			t.Run("wait", func(t *testing.T) {
+				q := newQueue()
+				p := new(P)
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					_ = q.Push(p)
+				}()
+				actual, err := q.PopTimeout(time.Second)
+				require.NoError(t, err)
+				assert.Same(t, p, actual)
+			})
+
+			t.Run("closed", func(t *testing.T) {
+				q := newQueue()
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := q.PopContext(context.Background())
+					errCh <- err
+				}()
+				time.Sleep(10 * time.Millisecond)
+				q.Close()
+				select {
+				case err := <-errCh:
+					assert.ErrorIs(t, err, Closed)
+				case <-time.After(time.Second):
+					t.Fatal("PopContext did not return after Close")
+				}
+				assert.ErrorIs(t, q.PushTimeout(new(P), time.Millisecond), Closed)
+			})
+		})
+	}
+}
+
+type popContextQueue interface {
+	Push(*P) error
+	PopContext(context.Context) (*P, error)
+	PopTimeout(time.Duration) (*P, error)
+	Close()
+}
+
+// delayNow pushes elements to a Delay queue that can be removed immediately.
+type delayNow struct {
+	*Delay[*P]
+}
+
+func (q delayNow) Push(p *P) error {
+	return q.PushAfter(p, 0)
+}
+
+func TestPopContext(t *testing.T) {
+	t.Parallel()
+
+	queues := map[string]func() popContextQueue{
+		"unbounded": func() popContextQueue {
+			return NewUnbounded[P, *P](2)
+		},
+		"delay": func() popContextQueue {
+			return delayNow{NewDelay[*P](nil)}
+		},
+	}
+
+	for name, newQueue := range queues {
+		newQueue := newQueue
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("success", func(t *testing.T) {
+				q := newQueue()
+				p := new(P)
+				require.NoError(t, q.Push(p))
+				actual, err := q.PopContext(context.Background())
+				require.NoError(t, err)
+				assert.Same(t, p, actual)
+
+				require.NoError(t, q.Push(p))
+				actual, err = q.PopTimeout(time.Millisecond)
+				require.NoError(t, err)
+				assert.Same(t, p, actual)
+			})
+
+			t.Run("timeout", func(t *testing.T) {
+				q := newQueue()
+				_, err := q.PopTimeout(10 * time.Millisecond)
+				assert.ErrorIs(t, err, TimeoutError)
+			})
+
+			t.Run("cancel", func(t *testing.T) {
+				q := newQueue()
+				ctx, cancel := context.WithCancel(context.Background())
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := q.PopContext(ctx)
+					errCh <- err
+				}()
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+				select {
+				case err := <-errCh:
+					assert.ErrorIs(t, err, context.Canceled)
+				case <-time.After(time.Second):
+					t.Fatal("PopContext did not return after cancel")
+				}
+			})
+
+			t.Run("wait", func(t *testing.T) {
+				q := newQueue()
+				p := new(P)
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					_ = q.Push(p)
+				}()
+				actual, err := q.PopTimeout(time.Second)
+				require.NoError(t, err)
+				assert.Same(t, p, actual)
+			})
+
+			t.Run("closed", func(t *testing.T) {
+				q := newQueue()
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := q.PopContext(context.Background())
+					errCh <- err
+				}()
+				time.Sleep(10 * time.Millisecond)
+				q.Close()
+				select {
+				case err := <-errCh:
+					assert.ErrorIs(t, err, Closed)
+				case <-time.After(time.Second):
+					t.Fatal("PopContext did not return after Close")
+				}
+				_, err := q.PopTimeout(time.Millisecond)
+				assert.ErrorIs(t, err, Closed)
+			})
+		})
+	}
+}
@@ -0,0 +1,216 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"goutils/clock"
+)
+
+// delayed is an element of a Delay queue with its deadline.
+type delayed[T any] struct {
+	value    T
+	deadline time.Time
+}
+
+// Delay is an unbounded queue whose elements can only be removed once their
+// deadline has passed, in the order of their deadlines. Elements with the same
+// deadline are removed in the order they were added.
+//
+// It is thread safe, Push never blocks and Pop blocks the caller until the
+// first deadline has passed. Time is read and waited for with a clock.Clock,
+// so that tests can use a clock.FakeClock.
+type Delay[T any] struct {
+	_padding0 [8]uint64 //nolint:structcheck,unused
+	heap      heap[delayed[T]]
+	_padding1 [8]uint64 //nolint:structcheck,unused
+	clock     clock.Clock
+	_padding2 [8]uint64 //nolint:structcheck,unused
+	closed    bool
+	_padding3 [8]uint64 //nolint:structcheck,unused
+	lock      *sync.Mutex
+	_padding4 [8]uint64 //nolint:structcheck,unused
+	// wake is closed and replaced to wake up waiting Pop calls
+	// when the first deadline changes or the queue is closed
+	wake chan struct{}
+}
+
+// NewDelay creates a new delay queue that uses c, or clock.RealClock if c is nil.
+func NewDelay[T any](c clock.Clock) *Delay[T] {
+	q := new(Delay[T])
+	if c == nil {
+		c = clock.RealClock{}
+	}
+	q.clock = c
+	q.lock = new(sync.Mutex)
+	q.wake = make(chan struct{})
+	q.heap = newHeap(0, func(a, b delayed[T]) bool {
+		return a.deadline.Before(b.deadline)
+	})
+	return q
+}
+
+// IsEmpty returns true if the queue is empty.
+func (q *Delay[T]) IsEmpty() (empty bool) {
+	q.lock.Lock()
+	empty = q.heap.len() == 0
+	q.lock.Unlock()
+	return
+}
+
+// IsClosed returns true if the queue is Closed
+//
+// The Drain method can be used to drain the queue after it is closed.
+func (q *Delay[T]) IsClosed() (closed bool) {
+	q.lock.Lock()
+	closed = q.closed
+	q.lock.Unlock()
+	return
+}
+
+// Length returns the number of elements in the queue, including
+// the ones whose deadline has not passed yet.
+func (q *Delay[T]) Length() (size int) {
+	q.lock.Lock()
+	size = q.heap.len()
+	q.lock.Unlock()
+	return
+}
+
+// Close closes the queue permanently.
+//
+// The Drain method can be used to drain the queue after it is closed.
+func (q *Delay[T]) Close() {
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.wake)
+	}
+	q.lock.Unlock()
+}
+
+// Push adds an element to the queue, that can be removed once deadline has passed.
+func (q *Delay[T]) Push(p T, deadline time.Time) error {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return Closed
+	}
+
+	q.heap.push(delayed[T]{value: p, deadline: deadline})
+	if q.heap.peek().deadline.Equal(deadline) {
+		// the element may be first now, so the waiters must wait for its deadline instead
+		close(q.wake)
+		q.wake = make(chan struct{})
+	}
+	q.lock.Unlock()
+	return nil
+}
+
+// PushAfter adds an element to the queue, that can be removed once d has passed.
+func (q *Delay[T]) PushAfter(p T, d time.Duration) error {
+	return q.Push(p, q.clock.Now().Add(d))
+}
+
+// Pop removes the element with the first deadline from the queue,
+// waiting until there is one and its deadline has passed.
+func (q *Delay[T]) Pop() (T, error) {
+	return q.PopContext(context.Background())
+}
+
+// PopContext removes the element with the first deadline from the queue like Pop,
+// but stops waiting with ctx.Err() once ctx is done.
+func (q *Delay[T]) PopContext(ctx context.Context) (p T, err error) {
+	for {
+		q.lock.Lock()
+		if q.closed {
+			q.lock.Unlock()
+			return p, Closed
+		}
+		wake := q.wake
+		if q.heap.len() == 0 {
+			q.lock.Unlock()
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return p, ctx.Err()
+			}
+			continue
+		}
+
+		wait := q.heap.peek().deadline.Sub(q.clock.Now())
+		if wait <= 0 {
+			p = q.heap.pop().value
+			q.lock.Unlock()
+			return p, nil
+		}
+		q.lock.Unlock()
+
+		timer := q.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return p, ctx.Err()
+		}
+	}
+}
+
+// PopTimeout removes the element with the first deadline from the queue like Pop,
+// but waits for at most d. TimeoutError is returned if there is no element in time.
+//
+// Unlike the deadlines of the elements, d is measured with the real time and not with the clock of the queue.
+func (q *Delay[T]) PopTimeout(d time.Duration) (T, error) {
+	return withTimeout(d, q.PopContext)
+}
+
+// TryPop removes the element with the first deadline from the queue without
+// waiting, returning EmptyError if the queue is empty or its deadline has not passed.
+func (q *Delay[T]) TryPop() (p T, err error) {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return p, Closed
+	}
+	if q.heap.len() == 0 || q.heap.peek().deadline.After(q.clock.Now()) {
+		q.lock.Unlock()
+		return p, EmptyError
+	}
+	p = q.heap.pop().value
+	q.lock.Unlock()
+	return
+}
+
+// Drain removes all elements from the queue, whether their
+// deadline has passed or not, and returns them in a slice in order.
+//
+// This function should only be called after the queue is closed.
+func (q *Delay[T]) Drain() (values []T) {
+	q.lock.Lock()
+	items := q.heap.drain()
+	q.lock.Unlock()
+	if len(items) == 0 {
+		return nil
+	}
+	values = make([]T, 0, len(items))
+	for _, item := range items {
+		values = append(values, item.value)
+	}
+	return values
+}
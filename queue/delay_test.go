@@ -0,0 +1,187 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"goutils/clock"
+)
+
+func TestDelay(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// waitForTimer waits until Pop waits for a timer of c.
+	waitForTimer := func(t *testing.T, c *clock.FakeClock) {
+		t.Helper()
+		require.Eventually(t, c.HasWaiters, time.Second, time.Millisecond)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		c := clock.NewFakeClock(start)
+		q := NewDelay[*P](c)
+		require.NoError(t, q.PushAfter(&P{Int: 2}, 2*time.Second))
+		require.NoError(t, q.PushAfter(&P{Int: 1}, time.Second))
+		require.NoError(t, q.Push(&P{Int: 3}, start.Add(2*time.Second)))
+		assert.Equal(t, 3, q.Length())
+
+		_, err := q.TryPop()
+		assert.ErrorIs(t, err, EmptyError)
+
+		c.Step(time.Second)
+		actual, err := q.TryPop()
+		require.NoError(t, err)
+		assert.Equal(t, 1, actual.Int)
+		_, err = q.TryPop()
+		assert.ErrorIs(t, err, EmptyError)
+
+		c.Step(time.Second)
+		actual, err = q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, 2, actual.Int)
+		actual, err = q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, 3, actual.Int)
+		assert.True(t, q.IsEmpty())
+	})
+
+	t.Run("pop waits for deadline", func(t *testing.T) {
+		c := clock.NewFakeClock(start)
+		q := NewDelay[*P](c)
+		require.NoError(t, q.PushAfter(&P{Int: 1}, time.Minute))
+
+		resultCh := make(chan *P, 1)
+		go func() {
+			p, err := q.Pop()
+			assert.NoError(t, err)
+			resultCh <- p
+		}()
+		waitForTimer(t, c)
+		c.Step(59 * time.Second)
+		select {
+		case <-resultCh:
+			t.Fatal("Pop returned before the deadline")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		c.Step(time.Second)
+		select {
+		case p := <-resultCh:
+			assert.Equal(t, 1, p.Int)
+		case <-time.After(time.Second):
+			t.Fatal("Pop did not return after the deadline")
+		}
+	})
+
+	t.Run("earlier push wakes pop", func(t *testing.T) {
+		c := clock.NewFakeClock(start)
+		q := NewDelay[*P](c)
+		require.NoError(t, q.PushAfter(&P{Int: 2}, time.Hour))
+
+		resultCh := make(chan *P, 1)
+		go func() {
+			p, err := q.Pop()
+			assert.NoError(t, err)
+			resultCh <- p
+		}()
+		waitForTimer(t, c)
+		require.NoError(t, q.PushAfter(&P{Int: 1}, time.Second))
+		// the waiter for the first deadline is replaced by one for the new deadline
+		require.Eventually(t, func() bool {
+			c.Step(time.Second)
+			return len(resultCh) == 1
+		}, time.Second, time.Millisecond)
+		p := <-resultCh
+		assert.Equal(t, 1, p.Int)
+		assert.Equal(t, 1, q.Length())
+	})
+
+	t.Run("pop waits for push", func(t *testing.T) {
+		c := clock.NewFakeClock(start)
+		q := NewDelay[*P](c)
+		resultCh := make(chan *P, 1)
+		go func() {
+			p, err := q.Pop()
+			assert.NoError(t, err)
+			resultCh <- p
+		}()
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, q.PushAfter(&P{Int: 1}, 0))
+		select {
+		case p := <-resultCh:
+			assert.Equal(t, 1, p.Int)
+		case <-time.After(time.Second):
+			t.Fatal("Pop did not return after Push")
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		c := clock.NewFakeClock(start)
+		q := NewDelay[*P](c)
+		require.NoError(t, q.PushAfter(&P{Int: 1}, time.Minute))
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := q.PopContext(ctx)
+			errCh <- err
+		}()
+		waitForTimer(t, c)
+		cancel()
+		assert.ErrorIs(t, <-errCh, context.Canceled)
+		assert.False(t, c.HasWaiters())
+		assert.Equal(t, 1, q.Length())
+	})
+
+	t.Run("buffer closed", func(t *testing.T) {
+		c := clock.NewFakeClock(start)
+		q := NewDelay[*P](c)
+		require.NoError(t, q.PushAfter(&P{Int: 2}, time.Minute))
+		require.NoError(t, q.PushAfter(&P{Int: 1}, time.Second))
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := q.Pop()
+			errCh <- err
+		}()
+		waitForTimer(t, c)
+		q.Close()
+		assert.ErrorIs(t, <-errCh, Closed)
+		assert.True(t, q.IsClosed())
+		assert.ErrorIs(t, q.PushAfter(new(P), 0), Closed)
+		_, err := q.TryPop()
+		assert.ErrorIs(t, err, Closed)
+
+		drained := q.Drain()
+		require.Len(t, drained, 2)
+		assert.Equal(t, 1, drained[0].Int)
+		assert.Equal(t, 2, drained[1].Int)
+		assert.Nil(t, q.Drain())
+	})
+
+	t.Run("real clock", func(t *testing.T) {
+		q := NewDelay[*P](nil)
+		require.NoError(t, q.PushAfter(&P{Int: 1}, 10*time.Millisecond))
+		start := time.Now()
+		actual, err := q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, 1, actual.Int)
+		assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	})
+}
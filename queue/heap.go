@@ -0,0 +1,108 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+// heapItem is an element of a heap, with the sequence number
+// of its insertion.
+type heapItem[T any] struct {
+	value T
+	seq   uint64
+}
+
+// heap is a binary heap of elements ordered by less, with
+// elements that are equal ordered by insertion.
+type heap[T any] struct {
+	items []heapItem[T]
+	less  func(a, b T) bool
+	seq   uint64
+}
+
+// newHeap creates a new heap ordered by less, with room for size elements.
+func newHeap[T any](size int, less func(a, b T) bool) heap[T] {
+	return heap[T]{items: make([]heapItem[T], 0, size), less: less}
+}
+
+// len returns the number of elements in the heap.
+func (h *heap[T]) len() int {
+	return len(h.items)
+}
+
+// before returns true if the element at i is ordered before the one at j.
+func (h *heap[T]) before(i, j int) bool {
+	if h.less(h.items[i].value, h.items[j].value) {
+		return true
+	}
+	if h.less(h.items[j].value, h.items[i].value) {
+		return false
+	}
+	return h.items[i].seq < h.items[j].seq
+}
+
+// push adds an element to the heap.
+func (h *heap[T]) push(value T) {
+	h.items = append(h.items, heapItem[T]{value: value, seq: h.seq})
+	h.seq++
+
+	i := len(h.items) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.before(i, parent) {
+			break
+		}
+		h.items[i], h.items[parent] = h.items[parent], h.items[i]
+		i = parent
+	}
+}
+
+// peek returns the first element of the non-empty heap.
+func (h *heap[T]) peek() T {
+	return h.items[0].value
+}
+
+// pop removes the first element of the non-empty heap.
+func (h *heap[T]) pop() T {
+	value := h.items[0].value
+	last := len(h.items) - 1
+	h.items[0] = h.items[last]
+	h.items[last] = heapItem[T]{}
+	h.items = h.items[:last]
+
+	i := 0
+	for {
+		first := i
+		if left := 2*i + 1; left < last && h.before(left, first) {
+			first = left
+		}
+		if right := 2*i + 2; right < last && h.before(right, first) {
+			first = right
+		}
+		if first == i {
+			return value
+		}
+		h.items[i], h.items[first] = h.items[first], h.items[i]
+		i = first
+	}
+}
+
+// drain removes all elements from the heap in order.
+func (h *heap[T]) drain() []T {
+	if len(h.items) == 0 {
+		return nil
+	}
+	values := make([]T, 0, len(h.items))
+	for len(h.items) > 0 {
+		values = append(values, h.pop())
+	}
+	return values
+}
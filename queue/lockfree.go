@@ -14,30 +14,53 @@
 package queue
 
 import (
+	"context"
 	"runtime"
 	"sync/atomic"
+	"time"
 )
 
+// closedBit is set in LockFree.head once the LockFree is closed, so that
+// no producer can reserve a slot after Close.
+const closedBit = 1 << 63
+
 type Pointer[T any] interface {
 	*T
 }
 
 // node is a struct that keeps track of its own position as well as a piece of data.
-type node[T any, P Pointer[T]] struct {
+//
+// The position of the slot for index i is 2*i while it is free and 2*i+1 once its data is written,
+// so that the two never collide even if the LockFree has a single slot.
+type node[T any] struct {
 	_padding0 [8]uint64 //nolint:structcheck,unused
 	position  uint64
 	_padding1 [8]uint64 //nolint:structcheck,unused
-	data      P
+	data      T
 }
 
 // nodes is a struct type containing a slice of node pointers
-type nodes[T any, P Pointer[T]] []*node[T, P]
+type nodes[T any] []*node[T]
 
 // LockFree is the struct used to store a blocking or non-blocking FIFO queue of type *packet.Packet
 //
-// In it's non-blocking form it acts as a ringbuffer, overwriting old data when new data arrives. In its blocking
-// form it waits for a space in the queue to open up before it adds the item to the LockFree.
+// In it's non-blocking form, created with NewLockFreeRing, it acts as a ringbuffer, overwriting old data when
+// new data arrives. In its blocking form, created with NewLockFree, it waits for a space in the queue to open up
+// before it adds the item to the LockFree.
+//
+// It is safe to be used by multiple producers and consumers concurrently.
 type LockFree[T any, P Pointer[T]] struct {
+	lockFree[P]
+}
+
+// LockFreeValue is a LockFree queue that stores
+// the items by value instead of by pointer.
+type LockFreeValue[T any] struct {
+	lockFree[T]
+}
+
+// lockFree implements LockFree and LockFreeValue for items of type T.
+type lockFree[T any] struct {
 	_padding0 [8]uint64 //nolint:structcheck,unused
 	head      uint64
 	_padding1 [8]uint64 //nolint:structcheck,unused
@@ -47,58 +70,77 @@ type LockFree[T any, P Pointer[T]] struct {
 	_padding3 [8]uint64 //nolint:structcheck,unused
 	closed    uint64
 	_padding4 [8]uint64 //nolint:structcheck,unused
-	nodes     []*node[T, P]
+	nodes     []*node[T]
 	_padding5 [8]uint64 //nolint:structcheck,unused
-	overflow  func() (uint64, error)
+	overflow  func(ctx context.Context) (uint64, error)
+	_padding6 [8]uint64 //nolint:structcheck,unused
+	dropped   uint64
 }
 
 // NewLockFree creates a new LockFree with blocking or non-blocking behavior
 func NewLockFree[T any, P Pointer[T]](size uint64) *LockFree[T, P] {
 	q := new(LockFree[T, P])
-	if size < 1 {
-		size = 1
-	}
 	q.overflow = q.blocker
 	q.init(size)
 	return q
 }
 
+// NewLockFreeValue creates a new LockFree of values with blocking behavior
+func NewLockFreeValue[T any](size uint64) *LockFreeValue[T] {
+	q := new(LockFreeValue[T])
+	q.overflow = q.blocker
+	q.init(size)
+	return q
+}
+
+// NewLockFreeRing creates a new LockFree with non-blocking behavior, whose Push never
+// waits for space and instead evicts the oldest item when the LockFree is full.
+// The number of evicted items is reported by Dropped.
+func NewLockFreeRing[T any, P Pointer[T]](size uint64) *LockFree[T, P] {
+	q := new(LockFree[T, P])
+	q.overflow = q.evicter
+	q.init(size)
+	return q
+}
+
+// NewLockFreeRingValue creates a new LockFree of values with non-blocking behavior, like NewLockFreeRing.
+func NewLockFreeRingValue[T any](size uint64) *LockFreeValue[T] {
+	q := new(LockFreeValue[T])
+	q.overflow = q.evicter
+	q.init(size)
+	return q
+}
+
 // init actually initializes a queue and can be used in the future to reuse LockFree structs
 // with their own pool
-func (q *LockFree[T, P]) init(size uint64) {
+func (q *lockFree[T]) init(size uint64) {
+	if size < 1 {
+		size = 1
+	}
 	size = round(size)
-	q.nodes = make(nodes[T, P], size)
+	q.nodes = make(nodes[T], size)
 	for i := uint64(0); i < size; i++ {
-		q.nodes[i] = &node[T, P]{position: i}
+		q.nodes[i] = &node[T]{position: 2 * i}
 	}
 	q.mask = size - 1
 }
 
 // blocker is a LockFree.overflow function that blocks a Push operation from
-// proceeding if the LockFree is ever full of data.
+// proceeding while the LockFree is full of data, and returns the head to retry with.
 //
-// If two Push operations happen simultaneously, blocker will block both of them until
-// a Pop takes place, and unblock both of them at the same time. This can cause problems,
-// however in our use case it won't because there shouldn't ever be more than one producer
-// operating on the LockFree at any given time. There may be multiple consumers in the future,
-// but that won't cause any problems.
+// Simultaneous Push operations are all unblocked by a Pop, after which they compete
+// for the free slot and the ones that lose call blocker again.
 //
-// If we decide to use this as an MPMC LockFree instead of a SPMC LockFree (which is how we currently use it)
-// then we can solve this bug by replacing the existing `default` switch case in the Push function with the
-// following snippet:
-// ```
-// default:
-//			head, err = q.overflow()
-//			if err != nil {
-//				return err
-//			}
-// ```
-func (q *LockFree[T, P]) blocker() (head uint64, err error) {
+// It stops blocking with Closed once the LockFree is closed, or with ctx.Err() once ctx is done.
+func (q *lockFree[T]) blocker(ctx context.Context) (head uint64, err error) {
 LOOP:
 	head = atomic.LoadUint64(&q.head)
+	if head&closedBit != 0 {
+		err = Closed
+		return
+	}
 	if uint64(len(q.nodes)) == head-atomic.LoadUint64(&q.tail) {
-		if atomic.LoadUint64(&q.closed) == 1 {
-			err = Closed
+		if err = ctx.Err(); err != nil {
 			return
 		}
 		runtime.Gosched()
@@ -107,47 +149,93 @@ LOOP:
 	return
 }
 
+// evicter is a LockFree.overflow function that makes space for a Push operation
+// while the LockFree is full of data by dropping the oldest item, and returns the head to retry with.
+//
+// It only waits for a producer that is still writing the oldest item, and stops with Closed
+// once the LockFree is closed.
+func (q *lockFree[T]) evicter(context.Context) (head uint64, err error) {
+	for {
+		head = atomic.LoadUint64(&q.head)
+		if head&closedBit != 0 {
+			err = Closed
+			return
+		}
+		oldPosition := atomic.LoadUint64(&q.tail)
+		if int64(head-oldPosition) < int64(len(q.nodes)) {
+			return
+		}
+
+		oldNode := q.nodes[oldPosition&q.mask]
+		if atomic.LoadUint64(&oldNode.position) == 2*oldPosition+1 {
+			if atomic.CompareAndSwapUint64(&q.tail, oldPosition, oldPosition+1) {
+				q.take(oldNode, oldPosition)
+				atomic.AddUint64(&q.dropped, 1)
+			}
+			continue
+		}
+		runtime.Gosched()
+	}
+}
+
 // Push appends an item of type *packet.Packet to the LockFree, and will block
-// until the item is pushed succ›essfully (with the blocking function depending
+// until the item is pushed successfully (with the blocking function depending
 // on whether this is a blocking LockFree).
 //
-// This method is not meant to be used concurrently, and the LockFree is meant to operate
-// as an SPMC LockFree with one producer operating at a time. If we want to use this as an MPMC LockFree
-// we can modify this Push function by replacing the existing `default` switch case with the
-// following snippet:
-// ```
-// default:
-//			head, err = q.overflow()
-//			if err != nil {
-//				return err
-//			}
-// ```
-func (q *LockFree[T, P]) Push(item P) error {
-	var newNode *node[T, P]
-	head, err := q.overflow()
-	if err != nil {
-		return err
-	}
-RETRY:
+// This method is safe to be used concurrently. Once it returns nil the item is
+// returned by exactly one Pop or Drain.
+func (q *lockFree[T]) Push(item T) error {
+	return q.push(context.Background(), item)
+}
+
+// PushContext appends an item to the LockFree like Push, but stops
+// waiting for space with ctx.Err() once ctx is done.
+func (q *lockFree[T]) PushContext(ctx context.Context, item T) error {
+	return q.push(ctx, item)
+}
+
+// PushTimeout appends an item to the LockFree like Push, but waits for space
+// for at most d. TimeoutError is returned if there is no space in time.
+func (q *lockFree[T]) PushTimeout(item T, d time.Duration) error {
+	_, err := withTimeout(d, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, q.push(ctx, item)
+	})
+	return err
+}
+
+// push is an internal function used to append an item to the LockFree,
+// ctx is only checked while waiting so that the fast path stays the same.
+//
+// A slot is reserved by moving the head past it, which fails once the LockFree is
+// closed as Close sets closedBit in the head.
+func (q *lockFree[T]) push(ctx context.Context, item T) error {
+	var newNode *node[T]
+	head := atomic.LoadUint64(&q.head)
 	for {
-		if atomic.LoadUint64(&q.closed) == 1 {
+		if head&closedBit != 0 {
 			return Closed
 		}
 
 		newNode = q.nodes[head&q.mask]
-		switch dif := atomic.LoadUint64(&newNode.position) - head; {
+		switch dif := int64(atomic.LoadUint64(&newNode.position) - 2*head); {
 		case dif == 0:
 			if atomic.CompareAndSwapUint64(&q.head, head, head+1) {
-				break RETRY
+				newNode.data = item
+				atomic.StoreUint64(&newNode.position, 2*head+1)
+				return nil
+			}
+			head = atomic.LoadUint64(&q.head)
+		case dif < 0:
+			// the slot still holds the item of the previous lap, so the LockFree is full
+			var err error
+			if head, err = q.overflow(ctx); err != nil {
+				return err
 			}
 		default:
+			// another producer already reserved the slot
 			head = atomic.LoadUint64(&q.head)
 		}
-		runtime.Gosched()
 	}
-	newNode.data = item
-	atomic.StoreUint64(&newNode.position, head+1)
-	return nil
 }
 
 // Pop removes an item from the start of the LockFree and returns it to the caller.
@@ -155,78 +243,231 @@ RETRY:
 // This allows for long-term listeners to wait on the LockFree until either an item is available
 // or the LockFree is closed.
 //
-// This method is safe to be used concurrently and is even optimized for the SPMC use case.
-func (q *LockFree[T, P]) Pop() (P, error) {
-	var oldNode *node[T, P]
+// This method is safe to be used concurrently.
+func (q *lockFree[T]) Pop() (T, error) {
+	return q.pop(context.Background())
+}
+
+// PopContext removes an item from the start of the LockFree like Pop, but stops
+// waiting for an item with ctx.Err() once ctx is done.
+func (q *lockFree[T]) PopContext(ctx context.Context) (T, error) {
+	return q.pop(ctx)
+}
+
+// PopTimeout removes an item from the start of the LockFree like Pop, but waits for
+// an item for at most d. TimeoutError is returned if there is no item in time.
+func (q *lockFree[T]) PopTimeout(d time.Duration) (T, error) {
+	return withTimeout(d, q.pop)
+}
+
+// pop is an internal function used to remove an item from the start of the LockFree,
+// ctx is only checked while waiting so that the fast path stays the same.
+func (q *lockFree[T]) pop(ctx context.Context) (data T, err error) {
+	var oldNode *node[T]
 	var oldPosition = atomic.LoadUint64(&q.tail)
-RETRY:
-	if atomic.LoadUint64(&q.closed) == 1 {
-		return nil, Closed
-	}
+	for {
+		if atomic.LoadUint64(&q.closed) == 1 {
+			return data, Closed
+		}
 
-	oldNode = q.nodes[oldPosition&q.mask]
-	switch dif := atomic.LoadUint64(&oldNode.position) - (oldPosition + 1); {
-	case dif == 0:
-		if atomic.CompareAndSwapUint64(&q.tail, oldPosition, oldPosition+1) {
-			goto DONE
+		oldNode = q.nodes[oldPosition&q.mask]
+		switch dif := int64(atomic.LoadUint64(&oldNode.position) - (2*oldPosition + 1)); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.tail, oldPosition, oldPosition+1) {
+				return q.take(oldNode, oldPosition), nil
+			}
+		case dif < 0:
+			// the slot is empty or its producer has not written it yet
+			if err = ctx.Err(); err != nil {
+				return data, err
+			}
+			runtime.Gosched()
 		}
-	default:
 		oldPosition = atomic.LoadUint64(&q.tail)
 	}
-	runtime.Gosched()
-	goto RETRY
-DONE:
+}
+
+// PushBatch appends the items to the LockFree in order, reserving as many slots as
+// are free with a single CAS and waiting for space for the rest like Push.
+//
+// It returns the number of items appended, which is less than len(items)
+// only if the LockFree is closed.
+func (q *lockFree[T]) PushBatch(items []T) (n int, err error) {
+	for n < len(items) {
+		// the tail never passes the head, so loading it first underestimates the free slots
+		tail := atomic.LoadUint64(&q.tail)
+		head := atomic.LoadUint64(&q.head)
+		if head&closedBit != 0 {
+			return n, Closed
+		}
+
+		free := int64(len(q.nodes)) - int64(head-tail)
+		if free <= 0 {
+			if _, err = q.overflow(context.Background()); err != nil {
+				return n, err
+			}
+			continue
+		}
+		count := uint64(min(free, int64(len(items)-n)))
+		if !atomic.CompareAndSwapUint64(&q.head, head, head+count) {
+			continue
+		}
+
+		for i := uint64(0); i < count; i++ {
+			newNode := q.nodes[(head+i)&q.mask]
+			// a consumer that reserved the item of the previous lap may still be reading it
+			for atomic.LoadUint64(&newNode.position) != 2*(head+i) {
+				runtime.Gosched()
+			}
+			newNode.data = items[n]
+			atomic.StoreUint64(&newNode.position, 2*(head+i)+1)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PopBatch removes up to max items from the start of the LockFree with a single CAS
+// and appends them to dst. Like Pop, it blocks until there is at least one item, but unblocks
+// when the LockFree is closed.
+func (q *lockFree[T]) PopBatch(dst []T, max int) ([]T, error) {
+	if max <= 0 {
+		return dst, nil
+	}
+	for {
+		if atomic.LoadUint64(&q.closed) == 1 {
+			return dst, Closed
+		}
+		var popped bool
+		if dst, popped = q.popBatch(dst, max); popped {
+			return dst, nil
+		}
+		runtime.Gosched()
+	}
+}
+
+// PopBatchTimeout removes up to max items from the start of the LockFree and appends them
+// to dst, waiting for at most d for there to be max items. The items removed until then are
+// returned as a partial batch, and TimeoutError only if there are none.
+//
+// If the LockFree is closed while waiting, the items removed until then are returned with Closed.
+func (q *lockFree[T]) PopBatchTimeout(dst []T, max int, d time.Duration) ([]T, error) {
+	if max <= 0 {
+		return dst, nil
+	}
+	var deadline time.Time
+	start := len(dst)
+	for {
+		if atomic.LoadUint64(&q.closed) == 1 {
+			return dst, Closed
+		}
+		dst, _ = q.popBatch(dst, max-(len(dst)-start))
+		if len(dst)-start == max {
+			return dst, nil
+		}
+
+		if deadline.IsZero() {
+			deadline = time.Now().Add(d)
+		} else if !time.Now().Before(deadline) {
+			if len(dst) == start {
+				return dst, TimeoutError
+			}
+			return dst, nil
+		}
+		runtime.Gosched()
+	}
+}
+
+// popBatch is an internal function used to remove up to max items from the start of the
+// LockFree without waiting and append them to dst, returning false if there are none.
+func (q *lockFree[T]) popBatch(dst []T, max int) ([]T, bool) {
+	for {
+		// the tail never passes the head, so it is loaded first
+		tail := atomic.LoadUint64(&q.tail)
+		available := int64(atomic.LoadUint64(&q.head)&^closedBit - tail)
+		if available <= 0 {
+			return dst, false
+		}
+		count := uint64(min(available, int64(max)))
+		if !atomic.CompareAndSwapUint64(&q.tail, tail, tail+count) {
+			continue
+		}
+
+		for i := uint64(0); i < count; i++ {
+			oldNode := q.nodes[(tail+i)&q.mask]
+			// a producer that reserved the slot may still be writing its item
+			for atomic.LoadUint64(&oldNode.position) != 2*(tail+i)+1 {
+				runtime.Gosched()
+			}
+			dst = append(dst, q.take(oldNode, tail+i))
+		}
+		return dst, true
+	}
+}
+
+// take is an internal function used to return the data of oldNode at oldPosition after
+// it was reserved by moving the tail past it, and to free the slot for the next lap.
+func (q *lockFree[T]) take(oldNode *node[T], oldPosition uint64) T {
+	var zero T
 	data := oldNode.data
-	oldNode.data = nil
-	atomic.StoreUint64(&oldNode.position, oldPosition+q.mask+1)
-	return data, nil
+	oldNode.data = zero
+	atomic.StoreUint64(&oldNode.position, 2*(oldPosition+q.mask+1))
+	return data
 }
 
 // Close marks the LockFree as closed, returns any waiting Pop() calls,
 // and blocks all future Push calls from occurring.
-func (q *LockFree[T, P]) Close() {
-	atomic.CompareAndSwapUint64(&q.closed, 0, 1)
+func (q *lockFree[T]) Close() {
+	atomic.StoreUint64(&q.closed, 1)
+	for {
+		head := atomic.LoadUint64(&q.head)
+		if head&closedBit != 0 || atomic.CompareAndSwapUint64(&q.head, head, head|closedBit) {
+			return
+		}
+	}
+}
+
+// Dropped is the number of items that were evicted by Push to make space for new ones,
+// which is always zero unless the LockFree was created with NewLockFreeRing.
+func (q *lockFree[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
 }
 
 // IsClosed returns whether the LockFree has been closed
-func (q *LockFree[T, P]) IsClosed() bool {
+func (q *lockFree[T]) IsClosed() bool {
 	return atomic.LoadUint64(&q.closed) == 1
 }
 
 // Length is the current number of items in the LockFree
-func (q *LockFree[T, P]) Length() int {
-	return int(atomic.LoadUint64(&q.head) - atomic.LoadUint64(&q.tail))
+func (q *lockFree[T]) Length() int {
+	// the tail never passes the head, so it is loaded first
+	tail := atomic.LoadUint64(&q.tail)
+	return int(atomic.LoadUint64(&q.head)&^closedBit - tail)
 }
 
 // Drain drains all the current packets in the queue and returns them to the caller.
 //
-// It is an unsafe function that should only be used once, only after the queue has been closed,
-// and only while there are no producers writing to it. If used incorrectly it has the potential
-// to infinitely block the caller. If used correctly, it allows a single caller to drain any remaining
-// packets in the queue after the queue has been closed.
-func (q *LockFree[T, P]) Drain() []P {
-	length := q.Length()
-	packets := make([]P, 0, length)
-	for i := 0; i < length; i++ {
-		var oldNode *node[T, P]
-		var oldPosition = atomic.LoadUint64(&q.tail)
-	RETRY:
-		oldNode = q.nodes[oldPosition&q.mask]
-		switch dif := atomic.LoadUint64(&oldNode.position) - (oldPosition + 1); {
-		case dif == 0:
+// It should be used after the queue has been closed, when it returns every item that was
+// pushed and not popped, waiting for producers that are still writing their item.
+// It is safe to be called concurrently with Pop calls that started before Close,
+// as every item is returned by either Pop or Drain, and with other Drain calls.
+// Before Close it returns the items that were pushed when it was called.
+func (q *lockFree[T]) Drain() []T {
+	head := atomic.LoadUint64(&q.head) &^ closedBit
+	packets := make([]T, 0, q.Length())
+	for {
+		oldPosition := atomic.LoadUint64(&q.tail)
+		if int64(head-oldPosition) <= 0 {
+			return packets
+		}
+
+		oldNode := q.nodes[oldPosition&q.mask]
+		if atomic.LoadUint64(&oldNode.position) == 2*oldPosition+1 {
 			if atomic.CompareAndSwapUint64(&q.tail, oldPosition, oldPosition+1) {
-				goto DONE
+				packets = append(packets, q.take(oldNode, oldPosition))
 			}
-		default:
-			oldPosition = atomic.LoadUint64(&q.tail)
+			continue
 		}
 		runtime.Gosched()
-		goto RETRY
-	DONE:
-		data := oldNode.data
-		oldNode.data = nil
-		atomic.StoreUint64(&oldNode.position, oldPosition+q.mask+1)
-		packets = append(packets, data)
 	}
-	return packets
 }
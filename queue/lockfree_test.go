@@ -14,6 +14,8 @@
 package queue
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -157,3 +159,243 @@ func TestLockFree(t *testing.T) {
 		assert.Equal(t, 0, rb.Length())
 	})
 }
+
+func TestLockFreeMPMC(t *testing.T) {
+	t.Parallel()
+
+	const producers, consumers, perProducer = 8, 8, 5000
+
+	// check that every pushed item was received exactly once, and nothing else
+	check := func(t *testing.T, pushed []bool, received [][]*P) {
+		t.Helper()
+		counts := make([]int, len(pushed))
+		for _, items := range received {
+			for _, p := range items {
+				require.NotNil(t, p)
+				counts[p.Int]++
+			}
+		}
+		for i, count := range counts {
+			if pushed[i] {
+				require.Equal(t, 1, count, "item %d", i)
+			} else {
+				require.Zero(t, count, "item %d", i)
+			}
+		}
+	}
+
+	t.Run("no loss or duplication", func(t *testing.T) {
+		t.Parallel()
+		rb := NewLockFree[P, *P](64)
+		pushed := make([]bool, producers*perProducer)
+		received := make([][]*P, consumers)
+
+		var popped atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < consumers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for popped.Load() < int64(len(pushed)) {
+					p, err := rb.PopTimeout(time.Millisecond)
+					if err == TimeoutError {
+						continue
+					}
+					if !assert.NoError(t, err) {
+						return
+					}
+					popped.Add(1)
+					received[i] = append(received[i], p)
+				}
+			}(i)
+		}
+		var producersWG sync.WaitGroup
+		for i := 0; i < producers; i++ {
+			producersWG.Add(1)
+			go func(i int) {
+				defer producersWG.Done()
+				for j := i * perProducer; j < (i+1)*perProducer; j++ {
+					if !assert.NoError(t, rb.Push(&P{Int: j})) {
+						return
+					}
+					pushed[j] = true
+				}
+			}(i)
+		}
+		producersWG.Wait()
+		wg.Wait()
+
+		assert.Equal(t, 0, rb.Length())
+		check(t, pushed, received)
+	})
+
+	t.Run("close and drain", func(t *testing.T) {
+		t.Parallel()
+		rb := NewLockFree[P, *P](64)
+		pushed := make([]bool, producers*perProducer)
+		received := make([][]*P, consumers+1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < consumers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for {
+					p, err := rb.Pop()
+					if err == Closed {
+						return
+					}
+					if !assert.NoError(t, err) {
+						return
+					}
+					received[i] = append(received[i], p)
+				}
+			}(i)
+		}
+		var producersWG sync.WaitGroup
+		for i := 0; i < producers; i++ {
+			producersWG.Add(1)
+			go func(i int) {
+				defer producersWG.Done()
+				for j := i * perProducer; j < (i+1)*perProducer; j++ {
+					err := rb.Push(&P{Int: j})
+					if err == Closed {
+						return
+					}
+					if !assert.NoError(t, err) {
+						return
+					}
+					pushed[j] = true
+				}
+			}(i)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		rb.Close()
+		// drain while the consumers and producers may still be finishing
+		received[consumers] = rb.Drain()
+		producersWG.Wait()
+		wg.Wait()
+
+		assert.ErrorIs(t, rb.Push(new(P)), Closed)
+		assert.Empty(t, rb.Drain())
+		check(t, pushed, received)
+	})
+
+	t.Run("drain with concurrent drains", func(t *testing.T) {
+		t.Parallel()
+		rb := NewLockFree[P, *P](1024)
+		pushed := make([]bool, 1024)
+		for i := range pushed {
+			require.NoError(t, rb.Push(&P{Int: i}))
+			pushed[i] = true
+		}
+		rb.Close()
+
+		received := make([][]*P, 4)
+		var wg sync.WaitGroup
+		for i := range received {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				received[i] = rb.Drain()
+			}(i)
+		}
+		wg.Wait()
+		check(t, pushed, received)
+	})
+}
+
+func TestLockFreeRing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("evicts oldest", func(t *testing.T) {
+		rb := NewLockFreeRing[P, *P](4)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, rb.Push(&P{Int: i}))
+		}
+		assert.Equal(t, 4, rb.Length())
+		assert.Equal(t, uint64(6), rb.Dropped())
+
+		for i := 6; i < 10; i++ {
+			actual, err := rb.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, i, actual.Int)
+		}
+		assert.Equal(t, 0, rb.Length())
+		assert.Equal(t, uint64(6), rb.Dropped())
+	})
+
+	t.Run("single slot", func(t *testing.T) {
+		rb := NewLockFreeRing[P, *P](1)
+		require.NoError(t, rb.Push(&P{Int: 1}))
+		require.NoError(t, rb.Push(&P{Int: 2}))
+		assert.Equal(t, uint64(1), rb.Dropped())
+
+		actual, err := rb.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, 2, actual.Int)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		rb := NewLockFreeRing[P, *P](1)
+		require.NoError(t, rb.Push(new(P)))
+		rb.Close()
+		assert.ErrorIs(t, rb.Push(new(P)), Closed)
+		assert.Equal(t, uint64(0), rb.Dropped())
+		assert.Len(t, rb.Drain(), 1)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+		const producers, consumers, perProducer = 8, 2, 5000
+		rb := NewLockFreeRing[P, *P](16)
+		received := make([][]*P, consumers+1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < consumers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for {
+					p, err := rb.Pop()
+					if err == Closed {
+						return
+					}
+					if !assert.NoError(t, err) {
+						return
+					}
+					received[i] = append(received[i], p)
+				}
+			}(i)
+		}
+		var producersWG sync.WaitGroup
+		for i := 0; i < producers; i++ {
+			producersWG.Add(1)
+			go func(i int) {
+				defer producersWG.Done()
+				for j := i * perProducer; j < (i+1)*perProducer; j++ {
+					if !assert.NoError(t, rb.Push(&P{Int: j})) {
+						return
+					}
+				}
+			}(i)
+		}
+		producersWG.Wait()
+		rb.Close()
+		received[consumers] = rb.Drain()
+		wg.Wait()
+
+		// every item is either received exactly once or dropped
+		seen := make([]bool, producers*perProducer)
+		total := 0
+		for _, items := range received {
+			for _, p := range items {
+				require.False(t, seen[p.Int], "item %d", p.Int)
+				seen[p.Int] = true
+				total++
+			}
+		}
+		assert.Equal(t, producers*perProducer, total+int(rb.Dropped()))
+	})
+}
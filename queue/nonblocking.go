@@ -14,7 +14,9 @@
 package queue
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 // NonBlocking is a circular sized FIFO queue that uses
@@ -24,6 +26,17 @@ import (
 // it is a blocking queue and will block the caller
 // if the queue is full or if it is empty.
 type NonBlocking[T any, P Pointer[T]] struct {
+	nonBlocking[P]
+}
+
+// NonBlockingValue is a NonBlocking queue that stores
+// the elements by value instead of by pointer.
+type NonBlockingValue[T any] struct {
+	nonBlocking[T]
+}
+
+// nonBlocking implements NonBlocking and NonBlockingValue for elements of type T.
+type nonBlocking[T any] struct {
 	_padding0 [8]uint64 //nolint:structcheck,unused
 	head      uint64
 	_padding1 [8]uint64 //nolint:structcheck,unused
@@ -35,12 +48,25 @@ type NonBlocking[T any, P Pointer[T]] struct {
 	_padding4 [8]uint64 //nolint:structcheck,unused
 	lock      *sync.Mutex
 	_padding5 [8]uint64 //nolint:structcheck,unused
-	nodes     []P
+	nodes     []T
 }
 
 // NewNonBlocking creates a new circular queue with the given size.
 func NewNonBlocking[T any, P Pointer[T]](maxSize uint64) *NonBlocking[T, P] {
 	q := new(NonBlocking[T, P])
+	q.init(maxSize)
+	return q
+}
+
+// NewNonBlockingValue creates a new circular queue of values with the given size.
+func NewNonBlockingValue[T any](maxSize uint64) *NonBlockingValue[T] {
+	q := new(NonBlockingValue[T])
+	q.init(maxSize)
+	return q
+}
+
+// init is an internal function used to initialize the queue with the given size.
+func (q *nonBlocking[T]) init(maxSize uint64) {
 	q.lock = new(sync.Mutex)
 	q.head = 0
 	q.tail = 0
@@ -51,12 +77,11 @@ func NewNonBlocking[T any, P Pointer[T]](maxSize uint64) *NonBlocking[T, P] {
 		q.maxSize = round(maxSize)
 	}
 
-	q.nodes = make([]P, q.maxSize)
-	return q
+	q.nodes = make([]T, q.maxSize)
 }
 
 // IsEmpty returns true if the queue is empty.
-func (q *NonBlocking[T, P]) IsEmpty() (empty bool) {
+func (q *nonBlocking[T]) IsEmpty() (empty bool) {
 	q.lock.Lock()
 	empty = q.isEmpty()
 	q.lock.Unlock()
@@ -65,12 +90,12 @@ func (q *NonBlocking[T, P]) IsEmpty() (empty bool) {
 
 // isEmpty is an internal function used to check if the
 // queue is empty.
-func (q *NonBlocking[T, P]) isEmpty() bool {
+func (q *nonBlocking[T]) isEmpty() bool {
 	return q.head == q.tail
 }
 
 // IsFull returns true if the queue is full.
-func (q *NonBlocking[T, P]) IsFull() (full bool) {
+func (q *nonBlocking[T]) IsFull() (full bool) {
 	q.lock.Lock()
 	full = q.isFull()
 	q.lock.Unlock()
@@ -79,14 +104,14 @@ func (q *NonBlocking[T, P]) IsFull() (full bool) {
 
 // isFull is an internal function used to check if the
 // queue is full.
-func (q *NonBlocking[T, P]) isFull() bool {
+func (q *nonBlocking[T]) isFull() bool {
 	return q.head == (q.tail+1)%q.maxSize
 }
 
 // IsClosed returns true if the queue is Closed
 //
 // The Drain method can be used to drain the queue after it is closed.
-func (q *NonBlocking[T, P]) IsClosed() (closed bool) {
+func (q *nonBlocking[T]) IsClosed() (closed bool) {
 	q.lock.Lock()
 	closed = q.isClosed()
 	q.lock.Unlock()
@@ -95,12 +120,12 @@ func (q *NonBlocking[T, P]) IsClosed() (closed bool) {
 
 // isClosed is an internal function used to check if the
 // queue is closed.
-func (q *NonBlocking[T, P]) isClosed() bool {
+func (q *nonBlocking[T]) isClosed() bool {
 	return q.closed
 }
 
 // Length returns the number of elements in the queue.
-func (q *NonBlocking[T, P]) Length() (size int) {
+func (q *nonBlocking[T]) Length() (size int) {
 	q.lock.Lock()
 	size = q.length()
 	q.lock.Unlock()
@@ -108,7 +133,7 @@ func (q *NonBlocking[T, P]) Length() (size int) {
 }
 
 // length is an internal function used to get the number of elements in the queue.
-func (q *NonBlocking[T, P]) length() int {
+func (q *nonBlocking[T]) length() int {
 	if q.tail < q.head {
 		return int(q.maxSize - q.head + q.tail)
 	}
@@ -118,14 +143,14 @@ func (q *NonBlocking[T, P]) length() int {
 // Close closes the queue permanently.
 //
 // The Drain method can be used to drain the queue after it is closed.
-func (q *NonBlocking[T, P]) Close() {
+func (q *nonBlocking[T]) Close() {
 	q.lock.Lock()
 	q.closed = true
 	q.lock.Unlock()
 }
 
 // Push adds an element to the queue.
-func (q *NonBlocking[T, P]) Push(p P) error {
+func (q *nonBlocking[T]) Push(p T) error {
 	q.lock.Lock()
 	if q.isClosed() {
 		q.lock.Unlock()
@@ -142,15 +167,15 @@ func (q *NonBlocking[T, P]) Push(p P) error {
 }
 
 // Pop removes an element from the queue.
-func (q *NonBlocking[T, P]) Pop() (p P, err error) {
+func (q *nonBlocking[T]) Pop() (p T, err error) {
 	q.lock.Lock()
 	if q.isClosed() {
 		q.lock.Unlock()
-		return nil, Closed
+		return p, Closed
 	}
 	if q.isEmpty() {
 		q.lock.Unlock()
-		return nil, EmptyError
+		return p, EmptyError
 	}
 
 	p = q.nodes[q.head]
@@ -159,20 +184,71 @@ func (q *NonBlocking[T, P]) Pop() (p P, err error) {
 	return
 }
 
+// PushContext adds an element to the queue, polling for space until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *nonBlocking[T]) PushContext(ctx context.Context, p T) error {
+	var b backoff
+	for {
+		if err := q.Push(p); err != FullError {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.wait()
+	}
+}
+
+// PushTimeout adds an element to the queue, polling for space for at most d.
+// TimeoutError is returned if there is no space in time.
+func (q *nonBlocking[T]) PushTimeout(p T, d time.Duration) error {
+	if err := q.Push(p); err != FullError {
+		return err
+	}
+	_, err := withTimeout(d, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, q.PushContext(ctx, p)
+	})
+	return err
+}
+
+// PopContext removes an element from the queue, polling for one until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *nonBlocking[T]) PopContext(ctx context.Context) (p T, err error) {
+	var b backoff
+	for {
+		if p, err = q.Pop(); err != EmptyError {
+			return p, err
+		}
+		if err = ctx.Err(); err != nil {
+			return p, err
+		}
+		b.wait()
+	}
+}
+
+// PopTimeout removes an element from the queue, polling for one for at most d.
+// TimeoutError is returned if there is no element in time.
+func (q *nonBlocking[T]) PopTimeout(d time.Duration) (T, error) {
+	if p, err := q.Pop(); err != EmptyError {
+		return p, err
+	}
+	return withTimeout(d, q.PopContext)
+}
+
 // Drain removes all elements from the queue.
 // and returns them in a slice.
 //
 // This function should only be called after the queue is closed.
-func (q *NonBlocking[T, P]) Drain() (values []P) {
+func (q *nonBlocking[T]) Drain() (values []T) {
 	q.lock.Lock()
 	if q.isEmpty() {
 		q.lock.Unlock()
 		return nil
 	}
 	if size := int(q.head) - int(q.tail); size > 0 {
-		values = make([]P, 0, size)
+		values = make([]T, 0, size)
 	} else {
-		values = make([]P, 0, -1*size)
+		values = make([]T, 0, -1*size)
 	}
 	for i := 0; i < cap(values); i++ {
 		values = append(values, q.nodes[q.head])
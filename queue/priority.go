@@ -0,0 +1,312 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority is a bounded priority queue that stores the elements in a heap,
+// ordered by a comparator. Elements that are equal are removed in the order
+// they were added.
+//
+// It is thread safe and will block the caller
+// if the queue is full or if it is empty.
+type Priority[T any] struct {
+	_padding0 [8]uint64 //nolint:structcheck,unused
+	heap      heap[T]
+	_padding1 [8]uint64 //nolint:structcheck,unused
+	maxSize   int
+	_padding2 [8]uint64 //nolint:structcheck,unused
+	closed    bool
+	_padding3 [8]uint64 //nolint:structcheck,unused
+	lock      *sync.Mutex
+	_padding4 [8]uint64 //nolint:structcheck,unused
+	notEmpty  *sync.Cond
+	_padding5 [8]uint64 //nolint:structcheck,unused
+	notFull   *sync.Cond
+}
+
+// NewPriority creates a new priority queue with the given size, that
+// removes the element for which less returns true compared to all others first.
+func NewPriority[T any](maxSize uint64, less func(a, b T) bool) *Priority[T] {
+	q := new(Priority[T])
+	q.lock = new(sync.Mutex)
+	q.notFull = sync.NewCond(q.lock)
+	q.notEmpty = sync.NewCond(q.lock)
+
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	q.maxSize = int(maxSize)
+	q.heap = newHeap(q.maxSize, less)
+	return q
+}
+
+// IsEmpty returns true if the queue is empty.
+func (q *Priority[T]) IsEmpty() (empty bool) {
+	q.lock.Lock()
+	empty = q.heap.len() == 0
+	q.lock.Unlock()
+	return
+}
+
+// IsFull returns true if the queue is full.
+func (q *Priority[T]) IsFull() (full bool) {
+	q.lock.Lock()
+	full = q.heap.len() == q.maxSize
+	q.lock.Unlock()
+	return
+}
+
+// IsClosed returns true if the queue is Closed
+//
+// The Drain method can be used to drain the queue after it is closed.
+func (q *Priority[T]) IsClosed() (closed bool) {
+	q.lock.Lock()
+	closed = q.closed
+	q.lock.Unlock()
+	return
+}
+
+// Length returns the number of elements in the queue.
+func (q *Priority[T]) Length() (size int) {
+	q.lock.Lock()
+	size = q.heap.len()
+	q.lock.Unlock()
+	return
+}
+
+// Close closes the queue permanently.
+//
+// The Drain method can be used to drain the queue after it is closed.
+func (q *Priority[T]) Close() {
+	q.lock.Lock()
+	q.closed = true
+	q.notFull.Broadcast()
+	q.notEmpty.Broadcast()
+	q.lock.Unlock()
+}
+
+// Push adds an element to the queue.
+func (q *Priority[T]) Push(p T) error {
+	q.lock.Lock()
+LOOP:
+	if q.closed {
+		q.lock.Unlock()
+		return Closed
+	}
+	if q.heap.len() == q.maxSize {
+		q.notFull.Wait()
+		goto LOOP
+	}
+
+	q.heap.push(p)
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return nil
+}
+
+// PushContext adds an element to the queue, waiting for space until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *Priority[T]) PushContext(ctx context.Context, p T) error {
+	if pushed, err := q.tryPush(p); pushed || err != nil {
+		return err
+	}
+	return q.pushContext(ctx, p)
+}
+
+// PushTimeout adds an element to the queue, waiting for space for at most d.
+// TimeoutError is returned if there is no space in time.
+func (q *Priority[T]) PushTimeout(p T, d time.Duration) error {
+	if pushed, err := q.tryPush(p); pushed || err != nil {
+		return err
+	}
+	_, err := withTimeout(d, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, q.pushContext(ctx, p)
+	})
+	return err
+}
+
+// tryPush is an internal function used to add an element to the
+// queue without waiting, returning false if the queue is full.
+func (q *Priority[T]) tryPush(p T) (bool, error) {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return false, Closed
+	}
+	if q.heap.len() == q.maxSize {
+		q.lock.Unlock()
+		return false, nil
+	}
+
+	q.heap.push(p)
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return true, nil
+}
+
+// pushContext is an internal function used to wait for space in the queue
+// until ctx is done, which wakes up the waiters.
+func (q *Priority[T]) pushContext(ctx context.Context, p T) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		q.notFull.Broadcast()
+		q.lock.Unlock()
+	})
+	defer stop()
+
+	q.lock.Lock()
+LOOP:
+	if q.closed {
+		q.lock.Unlock()
+		return Closed
+	}
+	if err := ctx.Err(); err != nil {
+		// pass on a wake up that was meant for another waiter
+		if q.heap.len() < q.maxSize {
+			q.notFull.Signal()
+		}
+		q.lock.Unlock()
+		return err
+	}
+	if q.heap.len() == q.maxSize {
+		q.notFull.Wait()
+		goto LOOP
+	}
+
+	q.heap.push(p)
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return nil
+}
+
+// Peek returns the first element of the queue without removing it,
+// or EmptyError if the queue is empty.
+func (q *Priority[T]) Peek() (p T, err error) {
+	q.lock.Lock()
+	if q.heap.len() == 0 {
+		q.lock.Unlock()
+		return p, EmptyError
+	}
+	p = q.heap.peek()
+	q.lock.Unlock()
+	return
+}
+
+// Pop removes the first element from the queue.
+func (q *Priority[T]) Pop() (p T, err error) {
+	q.lock.Lock()
+LOOP:
+	if q.closed {
+		q.lock.Unlock()
+		return p, Closed
+	}
+	if q.heap.len() == 0 {
+		q.notEmpty.Wait()
+		goto LOOP
+	}
+
+	p = q.heap.pop()
+	q.notFull.Signal()
+	q.lock.Unlock()
+	return
+}
+
+// PopContext removes the first element from the queue, waiting for one until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *Priority[T]) PopContext(ctx context.Context) (T, error) {
+	if p, popped, err := q.tryPop(); popped || err != nil {
+		return p, err
+	}
+	return q.popContext(ctx)
+}
+
+// PopTimeout removes the first element from the queue, waiting for one for at most d.
+// TimeoutError is returned if there is no element in time.
+func (q *Priority[T]) PopTimeout(d time.Duration) (T, error) {
+	if p, popped, err := q.tryPop(); popped || err != nil {
+		return p, err
+	}
+	return withTimeout(d, q.popContext)
+}
+
+// tryPop is an internal function used to remove the first element from the
+// queue without waiting, returning false if the queue is empty.
+func (q *Priority[T]) tryPop() (p T, popped bool, err error) {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return p, false, Closed
+	}
+	if q.heap.len() == 0 {
+		q.lock.Unlock()
+		return p, false, nil
+	}
+
+	p = q.heap.pop()
+	q.notFull.Signal()
+	q.lock.Unlock()
+	return p, true, nil
+}
+
+// popContext is an internal function used to wait for an element in the queue
+// until ctx is done, which wakes up the waiters.
+func (q *Priority[T]) popContext(ctx context.Context) (p T, err error) {
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		q.notEmpty.Broadcast()
+		q.lock.Unlock()
+	})
+	defer stop()
+
+	q.lock.Lock()
+LOOP:
+	if q.closed {
+		q.lock.Unlock()
+		return p, Closed
+	}
+	if err = ctx.Err(); err != nil {
+		// pass on a wake up that was meant for another waiter
+		if q.heap.len() > 0 {
+			q.notEmpty.Signal()
+		}
+		q.lock.Unlock()
+		return p, err
+	}
+	if q.heap.len() == 0 {
+		q.notEmpty.Wait()
+		goto LOOP
+	}
+
+	p = q.heap.pop()
+	q.notFull.Signal()
+	q.lock.Unlock()
+	return
+}
+
+// Drain removes all elements from the queue
+// and returns them in a slice, in order.
+//
+// This function should only be called after the queue is closed.
+func (q *Priority[T]) Drain() (values []T) {
+	q.lock.Lock()
+	values = q.heap.drain()
+	q.notFull.Broadcast()
+	q.lock.Unlock()
+	return values
+}
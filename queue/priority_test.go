@@ -0,0 +1,186 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriority(t *testing.T) {
+	t.Parallel()
+
+	less := func(a, b *P) bool {
+		return a.Int < b.Int
+	}
+
+	t.Run("success", func(t *testing.T) {
+		q := NewPriority[*P](8, less)
+		for _, i := range []int{5, 1, 4, 2, 3} {
+			require.NoError(t, q.Push(&P{Int: i}))
+		}
+		assert.Equal(t, 5, q.Length())
+
+		first, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, 1, first.Int)
+
+		for i := 1; i <= 5; i++ {
+			actual, err := q.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, i, actual.Int)
+		}
+		assert.True(t, q.IsEmpty())
+		_, err = q.Peek()
+		assert.ErrorIs(t, err, EmptyError)
+	})
+
+	t.Run("equal elements in order", func(t *testing.T) {
+		q := NewPriority[*P](8, less)
+		for i, s := range []string{"a", "b", "c", "d"} {
+			require.NoError(t, q.Push(&P{Int: i % 2, String: s}))
+		}
+		var actual []string
+		for i := 0; i < 4; i++ {
+			p, err := q.Pop()
+			require.NoError(t, err)
+			actual = append(actual, p.String)
+		}
+		assert.Equal(t, []string{"a", "c", "b", "d"}, actual)
+	})
+
+	t.Run("out of capacity, blocking", func(t *testing.T) {
+		q := NewPriority[*P](1, less)
+		require.NoError(t, q.Push(&P{Int: 2}))
+		assert.True(t, q.IsFull())
+		doneCh := make(chan struct{}, 1)
+		go func() {
+			assert.NoError(t, q.Push(&P{Int: 1}))
+			doneCh <- struct{}{}
+		}()
+		select {
+		case <-doneCh:
+			t.Fatal("Priority did not block on full write")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		actual, err := q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, 2, actual.Int)
+		<-doneCh
+		actual, err = q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, 1, actual.Int)
+	})
+
+	t.Run("buffer closed", func(t *testing.T) {
+		q := NewPriority[*P](4, less)
+		require.NoError(t, q.Push(&P{Int: 2}))
+		require.NoError(t, q.Push(&P{Int: 1}))
+		errCh := make(chan error, 1)
+		empty := NewPriority[*P](4, less)
+		go func() {
+			_, err := empty.Pop()
+			errCh <- err
+		}()
+		q.Close()
+		empty.Close()
+		assert.ErrorIs(t, <-errCh, Closed)
+		assert.True(t, q.IsClosed())
+		assert.ErrorIs(t, q.Push(new(P)), Closed)
+		_, err := q.Pop()
+		assert.ErrorIs(t, err, Closed)
+
+		drained := q.Drain()
+		require.Len(t, drained, 2)
+		assert.Equal(t, 1, drained[0].Int)
+		assert.Equal(t, 2, drained[1].Int)
+		assert.Nil(t, q.Drain())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+		const producers, consumers, perProducer = 4, 4, 2000
+		q := NewPriority[int](16, func(a, b int) bool {
+			return a < b
+		})
+		received := make([][]int, consumers)
+
+		var wg sync.WaitGroup
+		for i := 0; i < consumers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for {
+					p, err := q.Pop()
+					if err == Closed {
+						return
+					}
+					if !assert.NoError(t, err) {
+						return
+					}
+					received[i] = append(received[i], p)
+				}
+			}(i)
+		}
+		var producersWG sync.WaitGroup
+		for i := 0; i < producers; i++ {
+			producersWG.Add(1)
+			go func(i int) {
+				defer producersWG.Done()
+				for j := i * perProducer; j < (i+1)*perProducer; j++ {
+					if !assert.NoError(t, q.Push(j)) {
+						return
+					}
+				}
+			}(i)
+		}
+		producersWG.Wait()
+		for !q.IsEmpty() {
+			time.Sleep(time.Millisecond)
+		}
+		q.Close()
+		wg.Wait()
+
+		var all []int
+		for _, items := range received {
+			all = append(all, items...)
+		}
+		sort.Ints(all)
+		require.Len(t, all, producers*perProducer)
+		for i, p := range all {
+			require.Equal(t, i, p)
+		}
+	})
+}
+
+func TestHeap(t *testing.T) {
+	t.Parallel()
+
+	h := newHeap(0, func(a, b int) bool {
+		return a < b
+	})
+	values := []int{9, 3, 7, 1, 8, 2, 6, 4, 5, 0, 3, 7}
+	for _, v := range values {
+		h.push(v)
+	}
+	sort.Ints(values)
+	assert.Equal(t, values, h.drain())
+	assert.Equal(t, 0, h.len())
+}
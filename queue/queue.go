@@ -14,15 +14,81 @@
 package queue
 
 import (
+	"context"
 	"errors"
+	"runtime"
+	"time"
 )
 
 var (
-	Closed     = errors.New("queue is closed")
-	FullError  = errors.New("queue is full")
-	EmptyError = errors.New("queue is empty")
+	Closed       = errors.New("queue is closed")
+	FullError    = errors.New("queue is full")
+	EmptyError   = errors.New("queue is empty")
+	TimeoutError = errors.New("queue operation timed out")
 )
 
+// Queue is a queue of elements of type P, which is implemented by every FIFO
+// queue of the package, both the ones storing pointers and the ones storing values,
+// and by Priority. Delay does not implement it, as its elements are pushed with a deadline.
+type Queue[P any] interface {
+	// Push adds an element to the queue.
+	Push(p P) error
+	// Pop removes an element from the queue.
+	Pop() (P, error)
+	// Close closes the queue permanently.
+	Close()
+	// IsClosed returns true if the queue is closed.
+	IsClosed() bool
+	// Length returns the number of elements in the queue.
+	Length() int
+	// Drain removes all elements from the queue and returns them in a slice.
+	Drain() []P
+}
+
+var (
+	_ Queue[*struct{}] = (*Circular[struct{}, *struct{}])(nil)
+	_ Queue[*struct{}] = (*NonBlocking[struct{}, *struct{}])(nil)
+	_ Queue[*struct{}] = (*LockFree[struct{}, *struct{}])(nil)
+	_ Queue[*struct{}] = (*Unbounded[struct{}, *struct{}])(nil)
+	_ Queue[struct{}]  = (*CircularValue[struct{}])(nil)
+	_ Queue[struct{}]  = (*NonBlockingValue[struct{}])(nil)
+	_ Queue[struct{}]  = (*LockFreeValue[struct{}])(nil)
+	_ Queue[struct{}]  = (*UnboundedValue[struct{}])(nil)
+	_ Queue[struct{}]  = (*Priority[struct{}])(nil)
+)
+
+// maxBackoff is the longest a polling operation sleeps between attempts.
+const maxBackoff = time.Millisecond
+
+// withTimeout runs f with a context that is done after d, returning
+// TimeoutError instead of context.DeadlineExceeded.
+func withTimeout[R any](d time.Duration, f func(ctx context.Context) (R, error)) (R, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	r, err := f(ctx)
+	cancel()
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = TimeoutError
+	}
+	return r, err
+}
+
+// backoff is used by polling operations between attempts, it yields
+// the processor first and then sleeps for increasing durations up to maxBackoff.
+type backoff struct {
+	attempts int
+}
+
+// wait before the next attempt.
+func (b *backoff) wait() {
+	b.attempts++
+	if b.attempts < 16 {
+		runtime.Gosched()
+		return
+	}
+	d := time.Duration(1<<min(b.attempts-16, 10)) * time.Microsecond
+	time.Sleep(min(d, maxBackoff))
+}
+
 // round takes an uint64 value and rounds up to the nearest power of 2
 func round(value uint64) uint64 {
 	value--
@@ -15,6 +15,7 @@ package queue
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -42,3 +43,85 @@ func TestRound(t *testing.T) {
 		assert.Equalf(t, tc.expected, round(tc.in), "in: %d", tc.in)
 	}
 }
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	pointers := map[string]func() Queue[*P]{
+		"circular":     func() Queue[*P] { return NewCircular[P, *P](4) },
+		"non-blocking": func() Queue[*P] { return NewNonBlocking[P, *P](4) },
+		"lock-free":    func() Queue[*P] { return NewLockFree[P, *P](4) },
+		"unbounded":    func() Queue[*P] { return NewUnbounded[P, *P](4) },
+	}
+	values := map[string]func() Queue[P]{
+		"circular":     func() Queue[P] { return NewCircularValue[P](4) },
+		"non-blocking": func() Queue[P] { return NewNonBlockingValue[P](4) },
+		"lock-free":    func() Queue[P] { return NewLockFreeValue[P](4) },
+		"ring":         func() Queue[P] { return NewLockFreeRingValue[P](4) },
+		"unbounded":    func() Queue[P] { return NewUnboundedValue[P](4) },
+	}
+
+	for name, newQueue := range pointers {
+		newQueue := newQueue
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			q := newQueue()
+			for i := 0; i < 3; i++ {
+				require.NoError(t, q.Push(&P{Int: i}))
+			}
+			assert.Equal(t, 3, q.Length())
+			actual, err := q.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, 0, actual.Int)
+
+			q.Close()
+			assert.True(t, q.IsClosed())
+			_, err = q.Pop()
+			assert.ErrorIs(t, err, Closed)
+			drained := q.Drain()
+			require.Len(t, drained, 2)
+			assert.Equal(t, 1, drained[0].Int)
+			assert.Equal(t, 2, drained[1].Int)
+		})
+	}
+
+	for name, newQueue := range values {
+		newQueue := newQueue
+		t.Run(name+" value", func(t *testing.T) {
+			t.Parallel()
+			q := newQueue()
+			for i := 0; i < 3; i++ {
+				require.NoError(t, q.Push(P{Int: i, String: "value"}))
+			}
+			assert.Equal(t, 3, q.Length())
+			actual, err := q.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, P{Int: 0, String: "value"}, actual)
+
+			q.Close()
+			actual, err = q.Pop()
+			assert.ErrorIs(t, err, Closed)
+			assert.Zero(t, actual)
+			drained := q.Drain()
+			require.Len(t, drained, 2)
+			assert.Equal(t, 1, drained[0].Int)
+			assert.Equal(t, 2, drained[1].Int)
+		})
+	}
+}
+
+func TestValueAllocations(t *testing.T) {
+	queues := map[string]Queue[P]{
+		"circular":     NewCircularValue[P](4),
+		"non-blocking": NewNonBlockingValue[P](4),
+		"lock-free":    NewLockFreeValue[P](4),
+		"unbounded":    NewUnboundedValue[P](4),
+	}
+	for name, q := range queues {
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = q.Push(P{Int: 1})
+			_, _ = q.Pop()
+		})
+		assert.Zero(t, allocs, name)
+	}
+}
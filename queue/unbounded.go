@@ -0,0 +1,293 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// segment is a fixed size part of an Unbounded queue, which is linked
+// to the segment that is filled after it.
+type segment[T any] struct {
+	// head is the index of the next element to remove
+	head int
+	// tail is the index of the next element to add
+	tail  int
+	nodes []T
+	next  *segment[T]
+}
+
+// Unbounded is a FIFO queue without a maximum size, that stores the elements
+// in a linked list of fixed size segments. Segments are added as the queue grows,
+// and released once all their elements are removed.
+//
+// It is thread safe, Push never blocks and Pop blocks
+// the caller if the queue is empty.
+type Unbounded[T any, P Pointer[T]] struct {
+	unbounded[P]
+}
+
+// UnboundedValue is an Unbounded queue that stores
+// the elements by value instead of by pointer.
+type UnboundedValue[T any] struct {
+	unbounded[T]
+}
+
+// unbounded implements Unbounded and UnboundedValue for elements of type T.
+type unbounded[T any] struct {
+	_padding0   [8]uint64 //nolint:structcheck,unused
+	head        *segment[T]
+	_padding1   [8]uint64 //nolint:structcheck,unused
+	tail        *segment[T]
+	_padding2   [8]uint64 //nolint:structcheck,unused
+	spare       *segment[T]
+	_padding3   [8]uint64 //nolint:structcheck,unused
+	segmentSize int
+	_padding4   [8]uint64 //nolint:structcheck,unused
+	size        int
+	_padding5   [8]uint64 //nolint:structcheck,unused
+	closed      bool
+	_padding6   [8]uint64 //nolint:structcheck,unused
+	lock        *sync.Mutex
+	_padding7   [8]uint64 //nolint:structcheck,unused
+	notEmpty    *sync.Cond
+}
+
+// NewUnbounded creates a new unbounded queue, whose segments
+// have room for the given number of elements.
+func NewUnbounded[T any, P Pointer[T]](segmentSize uint64) *Unbounded[T, P] {
+	q := new(Unbounded[T, P])
+	q.init(segmentSize)
+	return q
+}
+
+// NewUnboundedValue creates a new unbounded queue of values, whose segments
+// have room for the given number of elements.
+func NewUnboundedValue[T any](segmentSize uint64) *UnboundedValue[T] {
+	q := new(UnboundedValue[T])
+	q.init(segmentSize)
+	return q
+}
+
+// init is an internal function used to initialize the queue with the given segment size.
+func (q *unbounded[T]) init(segmentSize uint64) {
+	q.lock = new(sync.Mutex)
+	q.notEmpty = sync.NewCond(q.lock)
+
+	if segmentSize < 2 {
+		q.segmentSize = 2
+	} else {
+		q.segmentSize = int(round(segmentSize))
+	}
+
+	q.head = q.newSegment()
+	q.tail = q.head
+}
+
+// newSegment is an internal function used to get an empty segment,
+// reusing the spare one if there is one.
+func (q *unbounded[T]) newSegment() *segment[T] {
+	if s := q.spare; s != nil {
+		q.spare = nil
+		return s
+	}
+	return &segment[T]{nodes: make([]T, q.segmentSize)}
+}
+
+// IsEmpty returns true if the queue is empty.
+func (q *unbounded[T]) IsEmpty() (empty bool) {
+	q.lock.Lock()
+	empty = q.size == 0
+	q.lock.Unlock()
+	return
+}
+
+// IsClosed returns true if the queue is Closed
+//
+// The Drain method can be used to drain the queue after it is closed.
+func (q *unbounded[T]) IsClosed() (closed bool) {
+	q.lock.Lock()
+	closed = q.closed
+	q.lock.Unlock()
+	return
+}
+
+// Length returns the number of elements in the queue.
+func (q *unbounded[T]) Length() (size int) {
+	q.lock.Lock()
+	size = q.size
+	q.lock.Unlock()
+	return
+}
+
+// Close closes the queue permanently.
+//
+// The Drain method can be used to drain the queue after it is closed.
+func (q *unbounded[T]) Close() {
+	q.lock.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.lock.Unlock()
+}
+
+// Push adds an element to the queue, adding a segment if the last one is full.
+func (q *unbounded[T]) Push(p T) error {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return Closed
+	}
+
+	if q.tail.tail == q.segmentSize {
+		s := q.newSegment()
+		q.tail.next = s
+		q.tail = s
+	}
+	q.tail.nodes[q.tail.tail] = p
+	q.tail.tail++
+	q.size++
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return nil
+}
+
+// Pop removes an element from the queue, releasing the first segment once it is empty.
+func (q *unbounded[T]) Pop() (p T, err error) {
+	q.lock.Lock()
+LOOP:
+	if q.closed {
+		q.lock.Unlock()
+		return p, Closed
+	}
+	if q.size == 0 {
+		q.notEmpty.Wait()
+		goto LOOP
+	}
+
+	p = q.pop()
+	q.lock.Unlock()
+	return
+}
+
+// PopContext removes an element from the queue, waiting for one until
+// the queue is closed or ctx is done, in which case ctx.Err() is returned.
+func (q *unbounded[T]) PopContext(ctx context.Context) (T, error) {
+	if p, popped, err := q.tryPop(); popped || err != nil {
+		return p, err
+	}
+	return q.popContext(ctx)
+}
+
+// PopTimeout removes an element from the queue, waiting for one for at most d.
+// TimeoutError is returned if there is no element in time.
+func (q *unbounded[T]) PopTimeout(d time.Duration) (T, error) {
+	if p, popped, err := q.tryPop(); popped || err != nil {
+		return p, err
+	}
+	return withTimeout(d, q.popContext)
+}
+
+// tryPop is an internal function used to remove an element from the
+// queue without waiting, returning false if the queue is empty.
+func (q *unbounded[T]) tryPop() (p T, popped bool, err error) {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return p, false, Closed
+	}
+	if q.size == 0 {
+		q.lock.Unlock()
+		return p, false, nil
+	}
+
+	p = q.pop()
+	q.lock.Unlock()
+	return p, true, nil
+}
+
+// popContext is an internal function used to wait for an element in the queue
+// until ctx is done, which wakes up the waiters.
+func (q *unbounded[T]) popContext(ctx context.Context) (p T, err error) {
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		q.notEmpty.Broadcast()
+		q.lock.Unlock()
+	})
+	defer stop()
+
+	q.lock.Lock()
+LOOP:
+	if q.closed {
+		q.lock.Unlock()
+		return p, Closed
+	}
+	if err = ctx.Err(); err != nil {
+		// pass on a wake up that was meant for another waiter
+		if q.size > 0 {
+			q.notEmpty.Signal()
+		}
+		q.lock.Unlock()
+		return p, err
+	}
+	if q.size == 0 {
+		q.notEmpty.Wait()
+		goto LOOP
+	}
+
+	p = q.pop()
+	q.lock.Unlock()
+	return
+}
+
+// pop is an internal function used to remove the first element of the non-empty queue.
+func (q *unbounded[T]) pop() (p T) {
+	var zero T
+	s := q.head
+	p = s.nodes[s.head]
+	s.nodes[s.head] = zero
+	s.head++
+	q.size--
+
+	if s.head == s.tail {
+		if s == q.tail {
+			// the only segment is empty, so it is reused from the start
+			s.head, s.tail = 0, 0
+			return
+		}
+		q.head = s.next
+		s.head, s.tail, s.next = 0, 0, nil
+		q.spare = s
+	}
+	return
+}
+
+// Drain removes all elements from the queue.
+// and returns them in a slice.
+//
+// This function should only be called after the queue is closed.
+func (q *unbounded[T]) Drain() (values []T) {
+	q.lock.Lock()
+	if q.size == 0 {
+		q.lock.Unlock()
+		return nil
+	}
+	values = make([]T, 0, q.size)
+	for q.size > 0 {
+		values = append(values, q.pop())
+	}
+	q.lock.Unlock()
+	return values
+}
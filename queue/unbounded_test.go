@@ -0,0 +1,147 @@
+/*
+	Copyright 2022 Loophole Labs
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		   http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnbounded(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		q := NewUnbounded[P, *P](4)
+		p := new(P)
+		require.NoError(t, q.Push(p))
+		actual, err := q.Pop()
+		require.NoError(t, err)
+		assert.Same(t, p, actual)
+		assert.True(t, q.IsEmpty())
+	})
+
+	t.Run("grows and releases segments", func(t *testing.T) {
+		q := NewUnbounded[P, *P](4)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, q.Push(&P{Int: i}))
+		}
+		assert.Equal(t, 10, q.Length())
+		assert.NotSame(t, q.head, q.tail)
+
+		for i := 0; i < 10; i++ {
+			actual, err := q.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, i, actual.Int)
+		}
+		assert.Equal(t, 0, q.Length())
+		assert.Same(t, q.head, q.tail)
+		assert.Nil(t, q.head.next)
+		assert.NotNil(t, q.spare)
+	})
+
+	t.Run("pop blocks until push", func(t *testing.T) {
+		q := NewUnbounded[P, *P](4)
+		p := new(P)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = q.Push(p)
+		}()
+		actual, err := q.Pop()
+		require.NoError(t, err)
+		assert.Same(t, p, actual)
+	})
+
+	t.Run("buffer closed", func(t *testing.T) {
+		q := NewUnbounded[P, *P](4)
+		for i := 0; i < 6; i++ {
+			require.NoError(t, q.Push(&P{Int: i}))
+		}
+		errCh := make(chan error, 1)
+		empty := NewUnbounded[P, *P](4)
+		go func() {
+			_, err := empty.Pop()
+			errCh <- err
+		}()
+		q.Close()
+		empty.Close()
+		assert.ErrorIs(t, <-errCh, Closed)
+		assert.True(t, q.IsClosed())
+		assert.ErrorIs(t, q.Push(new(P)), Closed)
+		_, err := q.Pop()
+		assert.ErrorIs(t, err, Closed)
+
+		values := q.Drain()
+		require.Len(t, values, 6)
+		for i, p := range values {
+			assert.Equal(t, i, p.Int)
+		}
+		assert.Nil(t, q.Drain())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+		const producers, consumers, perProducer = 8, 4, 5000
+		q := NewUnbounded[P, *P](16)
+		received := make([][]*P, consumers+1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < consumers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for {
+					p, err := q.Pop()
+					if err == Closed {
+						return
+					}
+					if !assert.NoError(t, err) {
+						return
+					}
+					received[i] = append(received[i], p)
+				}
+			}(i)
+		}
+		var producersWG sync.WaitGroup
+		for i := 0; i < producers; i++ {
+			producersWG.Add(1)
+			go func(i int) {
+				defer producersWG.Done()
+				for j := i * perProducer; j < (i+1)*perProducer; j++ {
+					if !assert.NoError(t, q.Push(&P{Int: j})) {
+						return
+					}
+				}
+			}(i)
+		}
+		producersWG.Wait()
+		q.Close()
+		wg.Wait()
+		received[consumers] = q.Drain()
+
+		seen := make([]bool, producers*perProducer)
+		for _, items := range received {
+			for _, p := range items {
+				require.False(t, seen[p.Int], "item %d", p.Int)
+				seen[p.Int] = true
+			}
+		}
+		for i, ok := range seen {
+			require.True(t, ok, "item %d", i)
+		}
+	})
+}
@@ -11,16 +11,30 @@ import (
 //https://github.com/arthurkushman/buildsqlx/blob/master/builder.go#L37
 
 type builder struct {
-	flavor          Flavor
-	db              ExecerAndQueryer
-	table           string
-	columns         []string
-	whereBindings   []map[string]any
-	orderBy         []map[string]string
-	groupBy         string
-	startBindingsAt int
-	offset          int64
-	limit           int64
+	flavor        Flavor
+	db            ExecerAndQueryer
+	table         string
+	fromSub       *builder
+	columns       []string
+	whereBindings []map[string]any
+	orderBy       []map[string]string
+	groupBy       string
+	offset        int64
+	limit         int64
+	unions        []union
+	ctes          []cte
+}
+
+// union is a query combined with UNION or UNION ALL.
+type union struct {
+	all bool
+	b   *builder
+}
+
+// cte is a common table expression of a WITH clause.
+type cte struct {
+	name string
+	b    *builder
 }
 
 func newBuilder(flavor Flavor, db ExecerAndQueryer) *builder {
@@ -41,10 +55,47 @@ func (b *builder) Select(columns ...string) *builder {
 	return b
 }
 
+// FromSub selects from the subquery sub named alias instead of a table.
+func (b *builder) FromSub(alias string, sub *builder) *builder {
+	b.table = alias
+	b.fromSub = sub
+	return b
+}
+
 func (b *builder) Where(column, operator string, value any) *builder {
 	return b.buildWhere("", column, operator, value)
 }
 
+// WhereInSub adds a "column IN (subquery)" condition.
+func (b *builder) WhereInSub(column string, sub *builder) *builder {
+	return b.buildWhere("", column, "IN", sub)
+}
+
+// WhereExists adds an "EXISTS (subquery)" condition.
+func (b *builder) WhereExists(sub *builder) *builder {
+	b.whereBindings = append(b.whereBindings, map[string]any{"EXISTS": sub})
+	return b
+}
+
+// Union combines the result of the query with the one of other, removing duplicates.
+// ORDER BY, LIMIT and OFFSET of the query apply to the combined result.
+func (b *builder) Union(other *builder) *builder {
+	b.unions = append(b.unions, union{b: other})
+	return b
+}
+
+// UnionAll combines the result of the query with the one of other, keeping duplicates.
+func (b *builder) UnionAll(other *builder) *builder {
+	b.unions = append(b.unions, union{all: true, b: other})
+	return b
+}
+
+// With adds the common table expression name defined by sub to the query.
+func (b *builder) With(name string, sub *builder) *builder {
+	b.ctes = append(b.ctes, cte{name: name, b: sub})
+	return b
+}
+
 func (b *builder) buildWhere(prefix, operand, operator string, val any) *builder {
 	if prefix != "" {
 		prefix = " " + prefix + " "
@@ -73,14 +124,69 @@ func (b *builder) Limit(limit int64) *builder {
 	return b
 }
 
-func (r *builder) buildSelect() string {
-	query := `SELECT ` + strings.Join(r.columns, `, `) + ` FROM ` + r.table + ``
+// ToSQL returns the SELECT query with the placeholders of the flavor and its arguments,
+// without executing it.
+func (b *builder) ToSQL() (string, []any) {
+	query, args := b.buildSelect()
+	return fixQuery(b.flavor, query), args
+}
+
+// buildSelect returns the SELECT query with ? placeholders and its arguments in placeholder order.
+func (r *builder) buildSelect() (string, []any) {
+	var args []any
+	query := r.buildWith(&args) + `SELECT ` + strings.Join(r.columns, `, `) + ` FROM ` + r.buildFrom(&args)
+	query += r.buildClauses(&args)
+	for _, u := range r.unions {
+		sub, subArgs := u.b.buildSelect()
+		if u.all {
+			query += " UNION ALL " + sub
+		} else {
+			query += " UNION " + sub
+		}
+		args = append(args, subArgs...)
+	}
+	return query + r.buildOrderLimit(), args
+}
+
+// buildDelete returns the DELETE query with ? placeholders and its arguments in placeholder order.
+func (r *builder) buildDelete() (string, []any) {
+	var args []any
+	query := r.buildWith(&args) + "DELETE FROM " + r.flavor.tableQuote("", r.table)
+	if len(r.whereBindings) > 0 {
+		query += composeWhere(r.whereBindings, &args)
+	}
+	return query, args
+}
+
+// builds the WITH clause of the common table expressions
+func (r *builder) buildWith(args *[]any) string {
+	if len(r.ctes) == 0 {
+		return ""
+	}
+	with := "WITH "
+	for i, c := range r.ctes {
+		if i > 0 {
+			with += ", "
+		}
+		sub, subArgs := c.b.buildSelect()
+		with += c.name + " AS (" + sub + ")"
+		*args = append(*args, subArgs...)
+	}
+	return with + " "
+}
 
-	return query + r.buildClauses()
+// builds the table or subquery to select from
+func (r *builder) buildFrom(args *[]any) string {
+	if r.fromSub == nil {
+		return r.table
+	}
+	sub, subArgs := r.fromSub.buildSelect()
+	*args = append(*args, subArgs...)
+	return "(" + sub + ") AS " + r.table
 }
 
 // builds query string clauses
-func (r *builder) buildClauses() string {
+func (r *builder) buildClauses(args *[]any) string {
 	clauses := ""
 	// for _, j := range r.join {
 	// 	clauses += j
@@ -88,7 +194,7 @@ func (r *builder) buildClauses() string {
 
 	// build where clause
 	if len(r.whereBindings) > 0 {
-		clauses += composeWhere(r.whereBindings, r.startBindingsAt)
+		clauses += composeWhere(r.whereBindings, args)
 	}
 
 	if r.groupBy != "" {
@@ -99,7 +205,12 @@ func (r *builder) buildClauses() string {
 	// 	clauses += " HAVING " + r.having
 	// }
 
-	clauses += composeOrderBy(r.orderBy)
+	return clauses
+}
+
+// builds the ORDER BY, LIMIT and OFFSET clauses, which come after any UNION
+func (r *builder) buildOrderLimit() string {
+	clauses := composeOrderBy(r.orderBy)
 
 	if r.limit > 0 {
 		clauses += " LIMIT " + strconv.FormatInt(r.limit, 10)
@@ -112,17 +223,25 @@ func (r *builder) buildClauses() string {
 	return clauses
 }
 
-// composes WHERE clause string for particular query stmt
-func composeWhere(whereBindings []map[string]any, startedAt int) string {
+// composes WHERE clause string for particular query stmt and appends its arguments to args
+func composeWhere(whereBindings []map[string]any, args *[]any) string {
 	where := " WHERE "
-	i := startedAt
-	for _, m := range whereBindings {
+	for i, m := range whereBindings {
 		for k, v := range m {
+			// conditions without an explicit prefix are joined with AND
+			if i > 0 && !strings.HasPrefix(k, " ") {
+				where += " AND "
+			}
 			// operand >= $i
 			switch vi := v.(type) {
+			case *builder:
+				sub, subArgs := vi.buildSelect()
+				where += k + " (" + sub + ")"
+				*args = append(*args, subArgs...)
 			case []any:
 				dataLen := len(vi)
 				where += k + " (" + strings.Repeat("?,", dataLen)[:dataLen*2-1] + ")"
+				*args = append(*args, prepareValue(vi)...)
 			default:
 				// if strings.Contains(k, sqlOperatorIs) || strings.Contains(k, sqlOperatorBetween) {
 				// 	where += k + " " + vi.(string)
@@ -130,7 +249,7 @@ func composeWhere(whereBindings []map[string]any, startedAt int) string {
 				// }
 
 				where += k + " ?"
-				i++
+				*args = append(*args, prepareValue(vi)...)
 			}
 		}
 	}
@@ -154,14 +273,6 @@ func composeOrderBy(orderBy []map[string]string) string {
 	}
 	return ""
 }
-func prepareValues(values []map[string]any) []any {
-	var vls []any
-	for _, v := range values {
-		_, vals, _ := prepareBindings(v)
-		vls = append(vls, vals...)
-	}
-	return vls
-}
 func prepareValue(value any) []any {
 	var values []any
 	switch v := value.(type) {
@@ -181,6 +292,8 @@ func prepareValue(value any) []any {
 		}
 	case nil:
 		values = append(values, nil)
+	default:
+		values = append(values, v)
 	}
 
 	return values
@@ -211,12 +324,11 @@ func prepareBindings(data map[string]any) (columns []string, values []any, bindi
 
 func (b *builder) Insert(data any) (sql.Result, error) {
 	defer b.Reset()
-	switch v := data.(type) {
-	case map[string]any:
-		return b.insertMap(v)
-	default:
-		return nil, fmt.Errorf("unsupported type %T", v)
+	m, err := toMap(data)
+	if err != nil {
+		return nil, err
 	}
+	return b.insertMap(m)
 }
 
 func (b *builder) insertMap(data map[string]any) (sql.Result, error) {
@@ -246,31 +358,41 @@ func (b *builder) updateMap(data map[string]any) (sql.Result, error) {
 		fields = append(fields, fmt.Sprintf("%s=?", b.flavor.columnQuote(k)))
 		values = append(values, v)
 	}
-	whereClause, whereArgs := composeWhere(b.whereBindings, 1), prepareValues(b.whereBindings)
-
-	query := "UPDATE " + b.flavor.tableQuote("", b.table) + " SET " + strings.Join(fields, ", ") + whereClause
-	values = append(values, whereArgs...)
+	query := "UPDATE " + b.flavor.tableQuote("", b.table) + " SET " + strings.Join(fields, ", ")
+	if len(b.whereBindings) > 0 {
+		query += composeWhere(b.whereBindings, &values)
+	}
 
 	return b.db.Exec(query, values...)
 }
 
+// Delete the rows matching the WHERE conditions.
+func (b *builder) Delete() (sql.Result, error) {
+	defer b.Reset()
+	query, args := b.buildDelete()
+	return b.db.Exec(query, args...)
+}
+
 func (b *builder) ScanRow(dest any) error {
-	query, args := b.buildSelect(), prepareValues(b.whereBindings)
+	query, args := b.buildSelect()
 	return Get(b.db, dest, query, args...)
 }
 
 func (b *builder) ScanRows(dest any) error {
 	defer b.Reset()
-	query, args := b.buildSelect(), prepareValues(b.whereBindings)
+	query, args := b.buildSelect()
 	return StructScanContext(context.Background(), b.db, dest, query, args...)
 }
 
 func (b *builder) Reset() {
 	b.table = ""
+	b.fromSub = nil
 	b.columns = []string{"*"}
 	b.whereBindings = make([]map[string]any, 0)
 	b.orderBy = make([]map[string]string, 0)
 	b.groupBy = ""
 	b.offset = 0
 	b.limit = 0
+	b.unions = nil
+	b.ctes = nil
 }
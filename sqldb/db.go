@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 )
 
 type Option func(opt *option)
@@ -33,18 +34,39 @@ func WithTraceSQL(traceSQL bool) Option {
 	}
 }
 
+// WithHealthCheck pings the database every interval in the background and fails
+// the ping after timeout. A zero timeout defaults to the interval.
+func WithHealthCheck(interval, timeout time.Duration) Option {
+	return func(opt *option) {
+		opt.HealthInterval = interval
+		opt.HealthTimeout = timeout
+	}
+}
+
+// WithHealthHook sets a function called when the health check changes state.
+func WithHealthHook(hook func(Health)) Option {
+	return func(opt *option) {
+		opt.OnHealthChange = hook
+	}
+}
+
 type option struct {
-	Prefix   string
-	Debug    bool
-	TraceSQL bool
-	Log      func(string, ...any)
+	Prefix         string
+	Debug          bool
+	TraceSQL       bool
+	Log            func(string, ...any)
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	OnHealthChange func(Health)
 }
 
 type DB struct {
 	*sql.DB
 	*builder
-	Flavor Flavor
-	Option option
+	Flavor   Flavor
+	Option   option
+	counters *counters
+	health   *healthChecker
 }
 
 // Open is the same as sql.Open, but returns an *sqlx.DB instead.
@@ -62,22 +84,10 @@ func Open(driverName, dataSourceName string, opts ...Option) (*DB, error) {
 	case "sqlite3", "sqlite", "nrsqlite3":
 		flavor = SQLite
 	default:
-		err = fmt.Errorf("unsupported driver: %s", driverName)
-	}
-	sqlDB := &DB{
-		DB:     db,
-		Flavor: flavor,
-		Option: option{
-			Prefix: "",
-			Debug:  false,
-			Log:    log.Printf,
-		},
-	}
-	for _, opt := range opts {
-		opt(&sqlDB.Option)
+		_ = db.Close()
+		return nil, fmt.Errorf("unsupported driver: %s", driverName)
 	}
-	sqlDB.builder = newBuilder(flavor, sqlDB)
-	return sqlDB, err
+	return NewSqlDB(db, flavor, opts...), nil
 }
 
 // Connect to a database and verify with a ping.
@@ -103,14 +113,31 @@ func NewSqlDB(db *sql.DB, flavor Flavor, opts ...Option) *DB {
 			Debug:  false,
 			Log:    log.Printf,
 		},
+		counters: new(counters),
+		health:   newHealthChecker(),
 	}
 	for _, opt := range opts {
 		opt(&sqlDB.Option)
 	}
 	sqlDB.builder = newBuilder(flavor, sqlDB)
+	if sqlDB.Option.HealthInterval > 0 {
+		sqlDB.health.start(sqlDB)
+	}
 	return sqlDB
 }
 
+// Builder returns a new query builder, independent of the one embedded in db,
+// to be used for subqueries, unions and common table expressions.
+func (db *DB) Builder() *builder {
+	return newBuilder(db.Flavor, db)
+}
+
+// Close stops the health check and closes the database.
+func (db *DB) Close() error {
+	db.health.close()
+	return db.DB.Close()
+}
+
 func (db *DB) Begin() (*Tx, error) {
 	return db.BeginTx(context.Background(), nil)
 }
@@ -121,9 +148,10 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 		return nil, err
 	}
 	return &Tx{
-		Tx:     tx,
-		Flavor: db.Flavor,
-		Option: db.Option,
+		Tx:       tx,
+		Flavor:   db.Flavor,
+		Option:   db.Option,
+		counters: db.counters,
 	}, nil
 }
 
@@ -190,11 +218,14 @@ func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.R
 		fmt.Println()
 	}
 	query = fixQuery(db.Flavor, query)
+	args = bindArgs(db.Flavor, args)
 	if opt.Debug {
 		start := Now()
 		defer opt.Log("query: %s, args: %v, time: %v\n", query, args, Since(start))
 	}
-	return db.DB.ExecContext(ctx, query, args...)
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	db.counters.count(err)
+	return res, err
 }
 
 func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
@@ -208,11 +239,14 @@ func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql
 		fmt.Println()
 	}
 	query = fixQuery(db.Flavor, query)
+	args = bindArgs(db.Flavor, args)
 	if opt.Debug {
 		start := Now()
 		defer opt.Log("query: %s, args: %v, time: %v\n", query, args, Since(start))
 	}
-	return db.DB.QueryContext(ctx, query, args...)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	db.counters.count(err)
+	return rows, err
 }
 
 func (db *DB) QueryRow(query string, args ...any) *sql.Row {
@@ -226,11 +260,14 @@ func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *s
 		fmt.Println()
 	}
 	query = fixQuery(db.Flavor, query)
+	args = bindArgs(db.Flavor, args)
 	if opt.Debug {
 		start := Now()
 		defer opt.Log("query: %s, args: %v, time: %v\n", query, args, Since(start))
 	}
-	return db.DB.QueryRowContext(ctx, query, args...)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	db.counters.count(row.Err())
+	return row
 }
 
 func (db *DB) Prepare(query string) (*sql.Stmt, error) {
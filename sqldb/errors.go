@@ -0,0 +1,274 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrorKind is the class of a database error.
+type ErrorKind int
+
+const (
+	UnknownError ErrorKind = iota
+	UniqueViolation
+	ForeignKeyViolation
+	NotNullViolation
+	Deadlock
+	Timeout
+)
+
+// String returns the name of k.
+func (k ErrorKind) String() string {
+	switch k {
+	case UniqueViolation:
+		return "unique violation"
+	case ForeignKeyViolation:
+		return "foreign key violation"
+	case NotNullViolation:
+		return "not null violation"
+	case Deadlock:
+		return "deadlock"
+	case Timeout:
+		return "timeout"
+	}
+	return "unknown"
+}
+
+// Error wraps an error returned by a database driver.
+// DB and Tx return the driver errors unchanged, use AsError or the Is functions to classify them.
+//
+// Code is the MySQL error number, the PostgreSQL SQLSTATE or the SQLite extended result code.
+// Constraint, Table and Column are filled in when the driver or the error message provides them.
+type Error struct {
+	Flavor     Flavor
+	Kind       ErrorKind
+	Code       string
+	Constraint string
+	Table      string
+	Column     string
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the driver error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// AsError returns the first driver error in the chain of err as an *Error.
+// It reports false if err does not contain an error of a supported driver.
+func AsError(err error) (*Error, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if dbErr, ok := e.(*Error); ok {
+			return dbErr, true
+		}
+		if dbErr := parseDriverError(e); dbErr != nil {
+			return dbErr, true
+		}
+	}
+	return nil, false
+}
+
+func errorKind(err error) ErrorKind {
+	if dbErr, ok := AsError(err); ok {
+		return dbErr.Kind
+	}
+	return UnknownError
+}
+
+// IsUniqueViolation reports whether err is caused by a unique or primary key constraint.
+func IsUniqueViolation(err error) bool {
+	return errorKind(err) == UniqueViolation
+}
+
+// IsForeignKeyViolation reports whether err is caused by a foreign key constraint.
+func IsForeignKeyViolation(err error) bool {
+	return errorKind(err) == ForeignKeyViolation
+}
+
+// IsNotNullViolation reports whether err is caused by a NULL in a NOT NULL column.
+func IsNotNullViolation(err error) bool {
+	return errorKind(err) == NotNullViolation
+}
+
+// IsDeadlock reports whether err is caused by a deadlock, the transaction can be retried.
+func IsDeadlock(err error) bool {
+	return errorKind(err) == Deadlock
+}
+
+// IsTimeout reports whether err is caused by a lock wait or statement timeout,
+// or by the deadline of the context.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errorKind(err) == Timeout
+}
+
+// driverPackages are the packages of the error types of the common drivers,
+// with the flavor of their databases.
+var driverPackages = map[string]Flavor{
+	"github.com/go-sql-driver/mysql": MySQL,
+	"github.com/lib/pq":              PostgreSQL,
+	"github.com/jackc/pgconn":        PostgreSQL,
+	"github.com/jackc/pgx/v5/pgconn": PostgreSQL,
+	"github.com/mattn/go-sqlite3":    SQLite,
+	"modernc.org/sqlite":             SQLite,
+}
+
+// parseDriverError recognizes the error types of the common drivers by their package
+// and their shape, so that no driver package has to be imported:
+//
+//	MySQL:      github.com/go-sql-driver/mysql.MySQLError{Number}
+//	PostgreSQL: github.com/lib/pq.Error{Code}, github.com/jackc/pgx/v5/pgconn.PgError{Code}
+//	SQLite:     github.com/mattn/go-sqlite3.Error{ExtendedCode}, modernc.org/sqlite.Error.Code()
+//
+// Errors of other packages are not recognized, even if they have the same shape.
+func parseDriverError(err error) *Error {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	flavor, ok := driverPackages[v.Type().PkgPath()]
+	if !ok {
+		return nil
+	}
+	switch flavor {
+	case MySQL:
+		if number, ok := uintField(v, "Number"); ok {
+			return MySQL.parseError(err, strconv.FormatUint(number, 10), reflect.Value{})
+		}
+	case SQLite:
+		if code, ok := intField(v, "ExtendedCode"); ok {
+			return SQLite.parseError(err, strconv.FormatInt(code, 10), reflect.Value{})
+		}
+		if coder, ok := err.(interface{ Code() int }); ok {
+			return SQLite.parseError(err, strconv.Itoa(coder.Code()), reflect.Value{})
+		}
+	case PostgreSQL:
+		if code, ok := stringField(v, "Code"); ok && len(code) == 5 {
+			return PostgreSQL.parseError(err, code, v)
+		}
+	}
+	return nil
+}
+
+var (
+	mysqlDuplicateKey = regexp.MustCompile(`for key '([^']+)'$`)
+	mysqlForeignKey   = regexp.MustCompile("fails \\(`[^`]+`\\.`([^`]+)`, CONSTRAINT `([^`]+)` FOREIGN KEY \\(`([^`]+)`\\)")
+	mysqlColumn       = regexp.MustCompile(`(?:Column|Field) '([^']+)'`)
+	sqliteColumn      = regexp.MustCompile(`constraint failed: ([\w.]+)`)
+)
+
+// parseError classifies err with code according to the conventions of f.
+// fields is the driver error struct, when the driver reports constraint details itself.
+func (f Flavor) parseError(err error, code string, fields reflect.Value) *Error {
+	dbErr := &Error{Flavor: f, Code: code, Err: err}
+	msg := err.Error()
+	switch f {
+	case MySQL:
+		switch code {
+		case "1062", "1169", "1586":
+			dbErr.Kind = UniqueViolation
+			if m := mysqlDuplicateKey.FindStringSubmatch(msg); m != nil {
+				// MySQL 8 reports the key as table.key
+				if table, key, ok := strings.Cut(m[1], "."); ok {
+					dbErr.Table, dbErr.Constraint = table, key
+				} else {
+					dbErr.Constraint = m[1]
+				}
+			}
+		case "1216", "1217", "1451", "1452":
+			dbErr.Kind = ForeignKeyViolation
+			if m := mysqlForeignKey.FindStringSubmatch(msg); m != nil {
+				dbErr.Table, dbErr.Constraint, dbErr.Column = m[1], m[2], m[3]
+			}
+		case "1048", "1364":
+			dbErr.Kind = NotNullViolation
+			if m := mysqlColumn.FindStringSubmatch(msg); m != nil {
+				dbErr.Column = m[1]
+			}
+		case "1213":
+			dbErr.Kind = Deadlock
+		case "1205", "3024":
+			dbErr.Kind = Timeout
+		}
+	case PostgreSQL:
+		switch code {
+		case "23505":
+			dbErr.Kind = UniqueViolation
+		case "23503":
+			dbErr.Kind = ForeignKeyViolation
+		case "23502":
+			dbErr.Kind = NotNullViolation
+		case "40P01":
+			dbErr.Kind = Deadlock
+		case "55P03", "57014":
+			dbErr.Kind = Timeout
+		}
+		if fields.IsValid() {
+			dbErr.Constraint, _ = stringField(fields, "Constraint", "ConstraintName")
+			dbErr.Table, _ = stringField(fields, "Table", "TableName")
+			dbErr.Column, _ = stringField(fields, "Column", "ColumnName")
+		}
+	case SQLite:
+		switch code {
+		case "1555", "2067":
+			dbErr.Kind = UniqueViolation
+		case "787":
+			dbErr.Kind = ForeignKeyViolation
+		case "1299":
+			dbErr.Kind = NotNullViolation
+		case "517":
+			// SQLITE_BUSY_SNAPSHOT, the transaction has to be restarted
+			dbErr.Kind = Deadlock
+		case "5", "261", "773":
+			dbErr.Kind = Timeout
+		}
+		if m := sqliteColumn.FindStringSubmatch(msg); m != nil {
+			// only the first column of a composite key is reported
+			if table, column, ok := strings.Cut(m[1], "."); ok {
+				dbErr.Table, dbErr.Column = table, column
+			}
+		}
+	}
+	return dbErr
+}
+
+func uintField(v reflect.Value, name string) (uint64, bool) {
+	f := v.FieldByName(name)
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return f.Uint(), true
+	}
+	return 0, false
+}
+
+func intField(v reflect.Value, name string) (int64, bool) {
+	f := v.FieldByName(name)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int(), true
+	}
+	return 0, false
+}
+
+func stringField(v reflect.Value, names ...string) (string, bool) {
+	for _, name := range names {
+		if f := v.FieldByName(name); f.Kind() == reflect.String {
+			return f.String(), true
+		}
+	}
+	return "", false
+}
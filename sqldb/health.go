@@ -0,0 +1,136 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health is the result of the last health check of a DB.
+type Health struct {
+	Healthy   bool
+	Err       error
+	CheckedAt time.Time
+}
+
+// Stats combines the pool statistics of database/sql with the sqldb counters.
+type Stats struct {
+	sql.DBStats
+	Queries   uint64
+	Errors    uint64
+	Commits   uint64
+	Rollbacks uint64
+	Health    Health
+}
+
+// counters are shared by a DB and the transactions started from it.
+type counters struct {
+	queries   atomic.Uint64
+	errors    atomic.Uint64
+	commits   atomic.Uint64
+	rollbacks atomic.Uint64
+}
+
+// count records a statement and whether it failed.
+func (c *counters) count(err error) {
+	if c == nil {
+		return
+	}
+	c.queries.Add(1)
+	if err != nil {
+		c.errors.Add(1)
+	}
+}
+
+// healthChecker pings the database in the background.
+type healthChecker struct {
+	lock     sync.RWMutex
+	health   Health
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{health: Health{Healthy: true}}
+}
+
+// start pings db every interval until Close is called.
+func (h *healthChecker) start(db *DB) {
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(db.Option.HealthInterval)
+		defer ticker.Stop()
+		for {
+			h.check(db)
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (h *healthChecker) check(db *DB) {
+	timeout := db.Option.HealthTimeout
+	if timeout <= 0 {
+		timeout = db.Option.HealthInterval
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	err := db.DB.PingContext(ctx)
+	cancel()
+
+	health := Health{Healthy: err == nil, Err: err, CheckedAt: time.Now()}
+	h.lock.Lock()
+	changed := h.health.Healthy != health.Healthy
+	h.health = health
+	h.lock.Unlock()
+	if changed && db.Option.OnHealthChange != nil {
+		db.Option.OnHealthChange(health)
+	}
+}
+
+func (h *healthChecker) get() Health {
+	if h == nil {
+		return Health{Healthy: true}
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.health
+}
+
+func (h *healthChecker) close() {
+	if h == nil || h.stop == nil {
+		return
+	}
+	h.stopOnce.Do(func() {
+		close(h.stop)
+	})
+	<-h.done
+}
+
+// Health returns the result of the last health check.
+// Without WithHealthCheck the DB is always reported healthy.
+func (db *DB) Health() Health {
+	return db.health.get()
+}
+
+// Stats returns the pool statistics together with the sqldb counters.
+func (db *DB) Stats() Stats {
+	stats := Stats{
+		DBStats: db.DB.Stats(),
+		Health:  db.Health(),
+	}
+	if c := db.counters; c != nil {
+		stats.Queries = c.queries.Load()
+		stats.Errors = c.errors.Load()
+		stats.Commits = c.commits.Load()
+		stats.Rollbacks = c.rollbacks.Load()
+	}
+	return stats
+}
@@ -49,7 +49,18 @@ type Queryer interface {
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-func Insert(ctx context.Context, flavor Flavor, prefix string, execer Execer, table string, data map[string]any) (sql.Result, error) {
+// Insert a row into table. data is either a map of column to value or a struct,
+// in which case the columns are taken from its fields the same way StructScanContext does.
+// Fields tagged with omitempty are left out when they are the zero value.
+func Insert(ctx context.Context, flavor Flavor, prefix string, execer Execer, table string, data any) (sql.Result, error) {
+	m, err := toMap(data)
+	if err != nil {
+		return nil, err
+	}
+	return insertMap(ctx, flavor, prefix, execer, table, m)
+}
+
+func insertMap(ctx context.Context, flavor Flavor, prefix string, execer Execer, table string, data map[string]any) (sql.Result, error) {
 	dataLen := len(data)
 	if dataLen == 0 {
 		return nil, fmt.Errorf("no data to insert")
@@ -63,7 +74,7 @@ func Insert(ctx context.Context, flavor Flavor, prefix string, execer Execer, ta
 	placeholder := flavor.placeHolder(dataLen)
 
 	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", flavor.tableQuote(prefix, table), strings.Join(fields, ","), placeholder)
-	return execer.ExecContext(ctx, query, values...)
+	return execer.ExecContext(ctx, query, bindArgs(flavor, values)...)
 }
 
 func StructScanContext(ctx context.Context, queryer Queryer, dest any, query string, args ...any) error {
@@ -98,18 +109,18 @@ func StructScanContext(ctx context.Context, queryer Queryer, dest any, query str
 		return err
 	}
 	scanArgs := make([]any, len(columns))
-	fieldMap := make(map[int][]int)
+	fieldMap := make(map[int]field)
 
 	// 预先计算字段索引
 	for _, field := range fields(base) {
 		if columnIndex := slices.Index(columns, field.name); columnIndex >= 0 {
-			fieldMap[columnIndex] = field.field.Index
+			fieldMap[columnIndex] = field
 		}
 	}
 	for rows.Next() {
 		vp = reflect.New(base)
-		for columnIndex, fieldIndex := range fieldMap {
-			scanArgs[columnIndex] = vp.Elem().FieldByIndex(fieldIndex).Addr().Interface()
+		for columnIndex, field := range fieldMap {
+			scanArgs[columnIndex] = field.addr(vp.Elem())
 		}
 		err = rows.Scan(scanArgs...)
 		if err != nil {
@@ -172,7 +183,7 @@ func fixQuery(flavor Flavor, query string) string {
 func Count(ctx context.Context, queryer Queryer, flavor Flavor, prefix, table string, where string, args ...any) (int, error) {
 	var count int
 	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", flavor.tableQuote(prefix, table), where)
-	err := queryer.QueryRowContext(ctx, query, args...).Scan(&count)
+	err := queryer.QueryRowContext(ctx, query, bindArgs(flavor, args)...).Scan(&count)
 	return count, err
 }
 
@@ -431,7 +442,7 @@ func Get(q Queryer, dest any, query string, args ...interface{}) error {
 		scanArgs := make([]any, len(columns))
 		for _, field := range fields(destElem.Type()) {
 			if columnIndex := slices.Index(columns, field.name); columnIndex >= 0 {
-				scanArgs[columnIndex] = destElem.FieldByIndex(field.field.Index).Addr().Interface()
+				scanArgs[columnIndex] = field.addr(destElem)
 			}
 		}
 
@@ -0,0 +1,232 @@
+package sqldbtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type fakeDriver struct{}
+
+// Open implements the driver.Driver interface.
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	m, ok := mocks.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("sqldbtest: unknown dsn %q, use New to open a database", dsn)
+	}
+	return &conn{mock: m.(*Mock)}, nil
+}
+
+// connector opens the connections of a database returned by New.
+type connector struct {
+	dsn string
+}
+
+// Connect implements the driver.Connector interface.
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return fakeDriver{}.Open(c.dsn)
+}
+
+// Driver implements the driver.Connector interface.
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+// Close releases the Mock of the database, it is called by sql.DB.Close.
+func (c *connector) Close() error {
+	mocks.Delete(c.dsn)
+	return nil
+}
+
+type conn struct {
+	mock *Mock
+}
+
+// Prepare implements the driver.Conn interface.
+// The statement is matched against the expectations when it is executed.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+// Close implements the driver.Conn interface.
+func (c *conn) Close() error {
+	return nil
+}
+
+// Begin implements the driver.Conn interface.
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements the driver.ConnBeginTx interface.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.mock.next(kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+	return &tx{conn: c}, nil
+}
+
+// ExecContext implements the driver.ExecerContext interface.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := c.mock.next(kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return e.result, nil
+}
+
+// QueryContext implements the driver.QueryerContext interface.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := c.mock.next(kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: e.rows}, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+// Commit implements the driver.Tx interface.
+func (t *tx) Commit() error {
+	_, err := t.conn.mock.next(kindCommit, "", nil)
+	return err
+}
+
+// Rollback implements the driver.Tx interface.
+func (t *tx) Rollback() error {
+	_, err := t.conn.mock.next(kindRollback, "", nil)
+	return err
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+// Close implements the driver.Stmt interface.
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput implements the driver.Stmt interface.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+// Exec implements the driver.Stmt interface.
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("sqldbtest: Exec is not supported, use ExecContext")
+}
+
+// Query implements the driver.Stmt interface.
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("sqldbtest: Query is not supported, use QueryContext")
+}
+
+// ExecContext implements the driver.StmtExecContext interface.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+// QueryContext implements the driver.StmtQueryContext interface.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// Rows are the rows returned by an expected Query.
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+	err     error
+}
+
+// NewRows returns empty Rows with the given columns.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row. It panics if the number of values does not match the number of columns
+// or if a value is not a valid driver.Value.
+func (r *Rows) AddRow(values ...any) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("sqldbtest: expected %d values, got %d", len(r.columns), len(values)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		v, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic("sqldbtest: " + err.Error())
+		}
+		if s, ok := v.(string); ok {
+			// drivers return text columns as []byte
+			v = []byte(s)
+		}
+		row[i] = v
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+// CloseError makes the iteration end with err after the last row.
+func (r *Rows) CloseError(err error) *Rows {
+	r.err = err
+	return r
+}
+
+type rows struct {
+	rows *Rows
+	pos  int
+}
+
+// Columns implements the driver.Rows interface.
+func (r *rows) Columns() []string {
+	return r.rows.columns
+}
+
+// Close implements the driver.Rows interface.
+func (r *rows) Close() error {
+	return nil
+}
+
+// Next implements the driver.Rows interface.
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows.values) {
+		if r.rows.err != nil {
+			return r.rows.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.pos])
+	r.pos++
+	return nil
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+// NewResult returns a driver.Result for WillReturnResult.
+func NewResult(lastInsertID, rowsAffected int64) driver.Result {
+	return result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+}
+
+// LastInsertId implements the driver.Result interface.
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+// RowsAffected implements the driver.Result interface.
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -0,0 +1,293 @@
+// Package sqldbtest provides an in-memory database/sql driver for testing code built on sqldb
+// without a real database.
+//
+// Every statement sent to the driver has to match the next expectation registered on the Mock.
+// Expected queries are regular expressions by default, so literal queries have to be quoted:
+//
+//	db, mock := sqldbtest.New()
+//	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM users WHERE name = $1`)).
+//		WithArgs("foo").
+//		WillReturnRows(sqldbtest.NewRows("name", "age").AddRow("foo", 20))
+//	sdb := sqldb.NewSqlDB(db, sqldb.PostgreSQL)
+//	...
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// Use WithQueryMatcher(MatchEqual) to compare the queries literally instead.
+package sqldbtest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DriverName is the name the driver is registered with in database/sql.
+const DriverName = "sqldbtest"
+
+var (
+	mocks   sync.Map // map[string]*Mock
+	nextDSN atomic.Uint64
+)
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+// QueryMatcher reports whether the query sent to the driver matches the expected one.
+type QueryMatcher func(expected, actual string) error
+
+// MatchRegexp matches when the expected query, as a regular expression, matches the actual query.
+// It is the default QueryMatcher.
+func MatchRegexp(expected, actual string) error {
+	re, err := regexp.Compile(expected)
+	if err != nil {
+		return err
+	}
+	if !re.MatchString(actual) {
+		return fmt.Errorf("query %q does not match regexp %q", actual, expected)
+	}
+	return nil
+}
+
+// MatchEqual matches when both queries are equal once whitespace is normalized.
+func MatchEqual(expected, actual string) error {
+	if normalize(expected) != normalize(actual) {
+		return fmt.Errorf("query %q is not equal to %q", actual, expected)
+	}
+	return nil
+}
+
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// Option configures a Mock.
+type Option func(m *Mock)
+
+// WithQueryMatcher sets the QueryMatcher used for ExpectExec and ExpectQuery.
+func WithQueryMatcher(matcher QueryMatcher) Option {
+	return func(m *Mock) {
+		m.matcher = matcher
+	}
+}
+
+// Mock holds the ordered expectations of a fake database.
+type Mock struct {
+	lock         sync.Mutex
+	matcher      QueryMatcher
+	expectations []*Expectation
+}
+
+// New opens a *sql.DB backed by the sqldbtest driver and returns it with its Mock.
+// The Mock is released when the *sql.DB is closed.
+func New(opts ...Option) (*sql.DB, *Mock) {
+	m := &Mock{matcher: MatchRegexp}
+	for _, opt := range opts {
+		opt(m)
+	}
+	dsn := "sqldbtest-" + strconv.FormatUint(nextDSN.Add(1), 10)
+	mocks.Store(dsn, m)
+	return sql.OpenDB(&connector{dsn: dsn}), m
+}
+
+type kind string
+
+const (
+	kindExec     kind = "Exec"
+	kindQuery    kind = "Query"
+	kindBegin    kind = "Begin"
+	kindCommit   kind = "Commit"
+	kindRollback kind = "Rollback"
+)
+
+// Expectation is a single statement expected by the Mock.
+type Expectation struct {
+	kind      kind
+	query     string
+	args      []any
+	checkArgs bool
+	result    driver.Result
+	rows      *Rows
+	err       error
+	triggered bool
+}
+
+// WithArgs sets the expected arguments. An argument implementing Argument is matched
+// with its Match method, any other value is compared after driver conversion.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = args
+	e.checkArgs = true
+	return e
+}
+
+// WillReturnResult sets the result of an expected Exec.
+func (e *Expectation) WillReturnResult(result driver.Result) *Expectation {
+	e.result = result
+	return e
+}
+
+// WillReturnRows sets the rows of an expected Query.
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnError makes the expected statement fail with err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// String returns a description of e used in error messages.
+func (e *Expectation) String() string {
+	if e.query == "" {
+		return string(e.kind)
+	}
+	if e.checkArgs {
+		return fmt.Sprintf("%s %q with args %v", e.kind, e.query, e.args)
+	}
+	return fmt.Sprintf("%s %q", e.kind, e.query)
+}
+
+func (m *Mock) expect(e *Expectation) *Expectation {
+	m.lock.Lock()
+	m.expectations = append(m.expectations, e)
+	m.lock.Unlock()
+	return e
+}
+
+// ExpectExec expects an Exec of query.
+func (m *Mock) ExpectExec(query string) *Expectation {
+	return m.expect(&Expectation{kind: kindExec, query: query, result: NewResult(0, 0)})
+}
+
+// ExpectQuery expects a Query of query.
+func (m *Mock) ExpectQuery(query string) *Expectation {
+	return m.expect(&Expectation{kind: kindQuery, query: query, rows: NewRows()})
+}
+
+// ExpectBegin expects the start of a transaction.
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(&Expectation{kind: kindBegin})
+}
+
+// ExpectCommit expects the commit of a transaction.
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(&Expectation{kind: kindCommit})
+}
+
+// ExpectRollback expects the rollback of a transaction.
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(&Expectation{kind: kindRollback})
+}
+
+// ExpectationsWereMet returns an error listing the expectations which were not triggered.
+func (m *Mock) ExpectationsWereMet() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var pending []string
+	for _, e := range m.expectations {
+		if !e.triggered {
+			pending = append(pending, e.String())
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("sqldbtest: %d expectations were not met: %s", len(pending), strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// next triggers the next pending expectation, which has to be of kind k and match query and args.
+func (m *Mock) next(k kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var e *Expectation
+	for _, expectation := range m.expectations {
+		if !expectation.triggered {
+			e = expectation
+			break
+		}
+	}
+	if e == nil {
+		if query != "" {
+			return nil, fmt.Errorf("sqldbtest: unexpected %s %q with args %v", k, query, namedValues(args))
+		}
+		return nil, fmt.Errorf("sqldbtest: unexpected %s", k)
+	}
+	if e.kind != k {
+		return nil, fmt.Errorf("sqldbtest: %s %q was called, but %s is expected", k, query, e)
+	}
+	if k == kindExec || k == kindQuery {
+		if err := m.matcher(e.query, query); err != nil {
+			return nil, fmt.Errorf("sqldbtest: %w", err)
+		}
+		if e.checkArgs {
+			if err := matchArgs(e.args, args); err != nil {
+				return nil, fmt.Errorf("sqldbtest: %s %q: %w", k, query, err)
+			}
+		}
+	}
+	e.triggered = true
+	return e, e.err
+}
+
+// Argument matches a single query argument.
+type Argument interface {
+	Match(driver.Value) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(driver.Value) bool {
+	return true
+}
+
+// AnyArg returns an Argument matching any value.
+func AnyArg() Argument {
+	return anyArg{}
+}
+
+func matchArgs(expected []any, actual []driver.NamedValue) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d arguments, got %d", len(expected), len(actual))
+	}
+	for i, arg := range expected {
+		if a, ok := arg.(Argument); ok {
+			if !a.Match(actual[i].Value) {
+				return fmt.Errorf("argument %d %v does not match", i, actual[i].Value)
+			}
+			continue
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return fmt.Errorf("argument %d: %w", i, err)
+		}
+		if !equal(v, actual[i].Value) {
+			return fmt.Errorf("argument %d is %v (%T), expected %v (%T)", i, actual[i].Value, actual[i].Value, v, v)
+		}
+	}
+	return nil
+}
+
+func equal(a, b driver.Value) bool {
+	if ab, ok := a.([]byte); ok {
+		bb, ok := b.([]byte)
+		return ok && string(ab) == string(bb)
+	}
+	return a == b
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
@@ -3,12 +3,57 @@ package sqldb
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"sync/atomic"
 )
 
 type field struct {
 	name  string
 	field reflect.StructField
+	// json marks a field tagged with the json option, e.g. `db:"meta,json"`,
+	// which is stored as JSON text.
+	json bool
+	// omitEmpty marks a field tagged with the omitempty option, e.g. `db:"id,omitempty"`,
+	// which is not inserted when it is the zero value, so that the database generates it.
+	omitEmpty bool
+}
+
+// newField parses a struct tag of the form "name,option..." and falls back to the field name.
+// Fields tagged with "-" are skipped by appendFields.
+func newField(tag string, f reflect.StructField) field {
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	fd := field{name: name, field: f}
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		switch opt {
+		case "json":
+			fd.json = true
+		case "omitempty":
+			fd.omitEmpty = true
+		}
+	}
+	return fd
+}
+
+// addr returns the scan destination of the field in v.
+func (f field) addr(v reflect.Value) any {
+	ptr := v.FieldByIndex(f.field.Index).Addr().Interface()
+	if f.json {
+		return jsonField{ptr}
+	}
+	return ptr
+}
+
+// value returns the value of the field in v to be used as a query argument.
+func (f field) value(v reflect.Value) any {
+	if f.json {
+		return jsonField{v.FieldByIndex(f.field.Index).Addr().Interface()}
+	}
+	return v.FieldByIndex(f.field.Index).Interface()
 }
 
 var cachedFields atomic.Value // map[reflect.Type][]field
@@ -24,11 +69,15 @@ func appendFields(fields []field, t reflect.Type, index []int) []field {
 					fields = appendFields(fields, f.Type, f.Index)
 				}
 			} else if s, ok := f.Tag.Lookup("sql"); ok {
-				fields = append(fields, field{s, f})
+				if s != "-" {
+					fields = append(fields, newField(s, f))
+				}
 			} else if s, ok := f.Tag.Lookup("db"); ok {
-				fields = append(fields, field{s, f})
+				if s != "-" {
+					fields = append(fields, newField(s, f))
+				}
 			} else {
-				fields = append(fields, field{f.Name, f})
+				fields = append(fields, field{name: f.Name, field: f})
 			}
 		}
 	}
@@ -58,3 +107,47 @@ func baseType(t reflect.Type, expected reflect.Kind) (reflect.Type, error) {
 	}
 	return t, nil
 }
+
+// toMap returns the column values of a map with string keys, a struct, or a pointer to either.
+// Maps of any named type are supported, as long as their keys are strings.
+func toMap(data any) (map[string]any, error) {
+	if m, ok := data.(map[string]any); ok {
+		return m, nil
+	}
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, fmt.Errorf("nil pointer passed as %T", data)
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Map {
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type of %T", data)
+		}
+		m := make(map[string]any, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m, nil
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported type %T", data)
+	}
+	if !value.CanAddr() {
+		// json fields are encoded through their address
+		v := reflect.New(value.Type()).Elem()
+		v.Set(value)
+		value = v
+	}
+	fs := fields(value.Type())
+	m := make(map[string]any, len(fs))
+	for _, f := range fs {
+		if f.omitEmpty && value.FieldByIndex(f.field.Index).IsZero() {
+			continue
+		}
+		m[f.name] = f.value(value)
+	}
+	return m, nil
+}
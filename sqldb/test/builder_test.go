@@ -0,0 +1,79 @@
+package test
+
+import (
+	"goutils/sqldb"
+	"goutils/sqldb/sqldbtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Run("where", func(t *testing.T) {
+		r := require.New(t)
+		db := sqldb.NewSqlDB(nil, sqldb.PostgreSQL)
+		query, args := db.Builder().Table("users").Where("name", "=", "foo").Where("age", ">", "18").ToSQL()
+		r.Equal("SELECT * FROM users WHERE name = $1 AND age > $2", query)
+		r.Equal([]any{"foo", "18"}, args)
+	})
+	t.Run("subqueries", func(t *testing.T) {
+		r := require.New(t)
+		for flavor, expected := range map[sqldb.Flavor]string{
+			sqldb.MySQL:      "SELECT name FROM users WHERE age > ? AND id IN (SELECT user_id FROM orders WHERE total > ?) AND EXISTS (SELECT id FROM bans WHERE active = ?)",
+			sqldb.PostgreSQL: "SELECT name FROM users WHERE age > $1 AND id IN (SELECT user_id FROM orders WHERE total > $2) AND EXISTS (SELECT id FROM bans WHERE active = $3)",
+		} {
+			db := sqldb.NewSqlDB(nil, flavor)
+			orders := db.Builder().Table("orders").Select("user_id").Where("total", ">", "100")
+			bans := db.Builder().Table("bans").Select("id").Where("active", "=", "1")
+			query, args := db.Builder().Table("users").Select("name").
+				Where("age", ">", "18").
+				WhereInSub("id", orders).
+				WhereExists(bans).
+				ToSQL()
+			r.Equal(expected, query)
+			r.Equal([]any{"18", "100", "1"}, args)
+		}
+	})
+	t.Run("from, with and union", func(t *testing.T) {
+		r := require.New(t)
+		db := sqldb.NewSqlDB(nil, sqldb.PostgreSQL)
+		active := db.Builder().Table("users").Where("active", "=", "t")
+		recent := db.Builder().Table("orders").Select("user_id", "SUM(total) AS total").Where("year", "=", "2024").GroupBy("user_id")
+		archived := db.Builder().Table("archived_users").Select("name").Where("year", "=", "2020")
+		query, args := db.Builder().
+			With("active_users", active).
+			FromSub("o", recent).
+			Select("o.user_id").
+			WhereInSub("o.user_id", db.Builder().Table("active_users").Select("id")).
+			Where("o.total", ">", "10").
+			UnionAll(archived).
+			OrderBy("1", "DESC").
+			Limit(5).
+			ToSQL()
+		r.Equal("WITH active_users AS (SELECT * FROM users WHERE active = $1) "+
+			"SELECT o.user_id FROM (SELECT user_id, SUM(total) AS total FROM orders WHERE year = $2 GROUP BY user_id) AS o "+
+			"WHERE o.user_id IN (SELECT id FROM active_users) AND o.total > $3 "+
+			"UNION ALL SELECT name FROM archived_users WHERE year = $4 ORDER BY 1 DESC LIMIT 5", query)
+		r.Equal([]any{"t", "2024", "10", "2020"}, args)
+	})
+	t.Run("delete", func(t *testing.T) {
+		r := require.New(t)
+		conn, mock := sqldbtest.New(sqldbtest.WithQueryMatcher(sqldbtest.MatchEqual))
+		defer conn.Close()
+		db := sqldb.NewSqlDB(conn, sqldb.PostgreSQL)
+		mock.ExpectExec(`DELETE FROM "sessions" WHERE user_id IN (SELECT id FROM users WHERE name = $1) AND expired = $2`).
+			WithArgs("foo", "1").
+			WillReturnResult(sqldbtest.NewResult(0, 3))
+		mock.ExpectExec(`DELETE FROM "sessions"`)
+
+		users := db.Builder().Table("users").Select("id").Where("name", "=", "foo")
+		res, err := db.Table("sessions").WhereInSub("user_id", users).Where("expired", "=", 1).Delete()
+		r.NoError(err)
+		affected, err := res.RowsAffected()
+		r.NoError(err)
+		r.Equal(int64(3), affected)
+		_, err = db.Table("sessions").Delete()
+		r.NoError(err)
+		r.NoError(mock.ExpectationsWereMet())
+	})
+}
@@ -138,6 +138,64 @@ func testGet(t *testing.T, db *sqldb.DB) {
 	r.Equal(int64(55), model.Age.Int64)
 }
 
+type row map[string]any
+
+type user struct {
+	Name  string `db:"name"`
+	Age   int    `db:"age,omitempty"`
+	Email string `db:"-"`
+}
+
+func testInsertStruct(t *testing.T, db *sqldb.DB) {
+	r := require.New(t)
+	_, err := db.Table("users").Insert(&user{Name: "bar", Age: 30, Email: "bar@example.com"})
+	r.NoError(err)
+	_, err = db.Table("users").Insert(user{Name: "baz"})
+	r.NoError(err)
+	_, err = db.Table("users").Insert(row{"name": "qux", "age": 40})
+	r.NoError(err)
+
+	var users []models.Users
+	r.NoError(db.StructScan(&users, "SELECT name, age FROM users WHERE name IN (?, ?, ?) ORDER BY name", "bar", "baz", "qux"))
+	r.Len(users, 3)
+	r.Equal(int64(30), users[0].Age.Int64)
+	r.False(users[1].Age.Valid)
+	r.Equal("qux", users[2].Name.String)
+	r.Equal(int64(40), users[2].Age.Int64)
+}
+
+func TestInsert(t *testing.T) {
+	r := require.New(t)
+	db, err := sqldb.Open("sqlite3", ":memory:")
+	r.NoError(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE test (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)")
+	r.NoError(err)
+
+	// the zero id is left out, so that the database generates it
+	for _, name := range []string{"foo", "bar"} {
+		res, err := db.Table("test").Insert(models.Test{Name: name})
+		r.NoError(err)
+		id, err := res.LastInsertId()
+		r.NoError(err)
+		r.NotZero(id)
+	}
+	_, err = db.Table("test").Insert(&models.Test{ID: 10, Name: "baz"})
+	r.NoError(err)
+
+	var tests []models.Test
+	r.NoError(db.StructScan(&tests, "SELECT * FROM test ORDER BY id"))
+	r.Equal([]models.Test{{ID: 1, Name: "foo"}, {ID: 2, Name: "bar"}, {ID: 10, Name: "baz"}}, tests)
+
+	_, err = db.Table("users").Insert(map[int]any{1: "foo"})
+	r.Error(err)
+
+	_, err = db.Exec("CREATE TABLE users (name TEXT, age INTEGER)")
+	r.NoError(err)
+	testInsertStruct(t, db)
+}
+
 func TestDB(t *testing.T) {
 	r := require.New(t)
 	ctx := context.Background()
@@ -168,6 +226,7 @@ func TestDB(t *testing.T) {
 		testUpdate(t, db)
 		testSelect(t, db)
 		testGet(t, db)
+		testInsertStruct(t, db)
 	})
 	t.Run("mysql", func(t *testing.T) {
 		db, err := sqldb.Open("mysql", "root:admin@tcp(127.0.0.1:3306)/test")
@@ -195,6 +254,7 @@ func TestDB(t *testing.T) {
 		testUpdate(t, db)
 		testSelect(t, db)
 		testGet(t, db)
+		testInsertStruct(t, db)
 	})
 	t.Run("postgres", func(t *testing.T) {
 		db, err := sqldb.Open("postgres", "user=postgres password=admin dbname=postgres sslmode=disable")
@@ -222,6 +282,7 @@ func TestDB(t *testing.T) {
 		testUpdate(t, db)
 		testSelect(t, db)
 		testGet(t, db)
+		testInsertStruct(t, db)
 	})
 }
 
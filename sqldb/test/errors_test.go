@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goutils/sqldb"
+	"strconv"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("sqlite3", func(t *testing.T) {
+		r := require.New(t)
+		db, err := sqldb.Open("sqlite3", "file::memory:?_foreign_keys=1")
+		r.NoError(err)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		_, err = db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE)")
+		r.NoError(err)
+		_, err = db.Exec("CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id))")
+		r.NoError(err)
+		_, err = db.Exec("INSERT INTO users (id, email) VALUES (?, ?)", 1, "foo@example.com")
+		r.NoError(err)
+
+		_, err = db.Exec("INSERT INTO users (id, email) VALUES (?, ?)", 2, "foo@example.com")
+		r.True(sqldb.IsUniqueViolation(err))
+		var sqliteErr sqlite3.Error
+		r.True(errors.As(err, &sqliteErr))
+		r.Equal(sqlite3.ErrConstraintUnique, sqliteErr.ExtendedCode)
+		dbErr, ok := sqldb.AsError(err)
+		r.True(ok)
+		r.Equal(sqldb.SQLite, dbErr.Flavor)
+		r.Equal("users", dbErr.Table)
+		r.Equal("email", dbErr.Column)
+
+		var id int64
+		err = db.QueryRow("INSERT INTO users (id, email) VALUES (?, ?) RETURNING id", 2, "foo@example.com").Scan(&id)
+		r.True(sqldb.IsUniqueViolation(err))
+		r.True(errors.As(err, &sqliteErr))
+
+		_, err = db.Exec("INSERT INTO users (id, email) VALUES (?, ?)", 3, nil)
+		r.True(sqldb.IsNotNullViolation(err))
+		r.False(sqldb.IsUniqueViolation(err))
+
+		_, err = db.Exec("INSERT INTO posts (id, user_id) VALUES (?, ?)", 1, 42)
+		r.True(sqldb.IsForeignKeyViolation(err))
+
+		err = db.Transaction(func(tx *sqldb.Tx) error {
+			_, err := tx.Exec("INSERT INTO users (id, email) VALUES (?, ?)", 1, "bar@example.com")
+			return fmt.Errorf("insert user: %w", err)
+		})
+		r.True(sqldb.IsUniqueViolation(err))
+	})
+	t.Run("mysql", func(t *testing.T) {
+		r := require.New(t)
+		err := fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'foo' for key 'users.email'"})
+		r.True(sqldb.IsUniqueViolation(err))
+		dbErr, ok := sqldb.AsError(err)
+		r.True(ok)
+		r.Equal(sqldb.MySQL, dbErr.Flavor)
+		r.Equal("1062", dbErr.Code)
+		r.Equal("users", dbErr.Table)
+		r.Equal("email", dbErr.Constraint)
+
+		err = &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`test`.`posts`, CONSTRAINT `posts_user_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"}
+		r.True(sqldb.IsForeignKeyViolation(err))
+		dbErr, _ = sqldb.AsError(err)
+		r.Equal("posts", dbErr.Table)
+		r.Equal("posts_user_fk", dbErr.Constraint)
+		r.Equal("user_id", dbErr.Column)
+
+		err = &mysql.MySQLError{Number: 1048, Message: "Column 'email' cannot be null"}
+		r.True(sqldb.IsNotNullViolation(err))
+		dbErr, _ = sqldb.AsError(err)
+		r.Equal("email", dbErr.Column)
+
+		r.True(sqldb.IsDeadlock(&mysql.MySQLError{Number: 1213}))
+		r.True(sqldb.IsTimeout(&mysql.MySQLError{Number: 1205}))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		r := require.New(t)
+		err := fmt.Errorf("insert: %w", &pq.Error{Code: "23505", Table: "users", Constraint: "users_email_key"})
+		r.True(sqldb.IsUniqueViolation(err))
+		dbErr, ok := sqldb.AsError(err)
+		r.True(ok)
+		r.Equal(sqldb.PostgreSQL, dbErr.Flavor)
+		r.Equal("users", dbErr.Table)
+		r.Equal("users_email_key", dbErr.Constraint)
+
+		r.True(sqldb.IsForeignKeyViolation(&pq.Error{Code: "23503"}))
+		r.True(sqldb.IsNotNullViolation(&pq.Error{Code: "23502", Column: "email"}))
+		r.True(sqldb.IsDeadlock(&pq.Error{Code: "40P01"}))
+		r.True(sqldb.IsTimeout(&pq.Error{Code: "57014"}))
+		r.False(sqldb.IsTimeout(&pq.Error{Code: "42P01"}))
+	})
+	t.Run("other", func(t *testing.T) {
+		r := require.New(t)
+		_, ok := sqldb.AsError(errors.New("boom"))
+		r.False(ok)
+		r.False(sqldb.IsUniqueViolation(nil))
+		r.True(sqldb.IsTimeout(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+
+		// errors of other packages with the shape of a driver error are not recognized
+		_, ok = sqldb.AsError(fmt.Errorf("call: %w", codeError{code: 19}))
+		r.False(ok)
+		_, ok = sqldb.AsError(&stateError{Code: "23505"})
+		r.False(ok)
+		r.False(sqldb.IsUniqueViolation(&stateError{Code: "23505"}))
+	})
+}
+
+// codeError has the shape of a modernc.org/sqlite error.
+type codeError struct {
+	code int
+}
+
+func (e codeError) Error() string { return "code " + strconv.Itoa(e.code) }
+func (e codeError) Code() int     { return e.code }
+
+// stateError has the shape of a PostgreSQL error.
+type stateError struct {
+	Code string
+}
+
+func (e *stateError) Error() string { return "state " + e.Code }
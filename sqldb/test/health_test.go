@@ -0,0 +1,93 @@
+package test
+
+import (
+	"errors"
+	"goutils/sqldb"
+	"goutils/sqldb/sqldbtest"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	r := require.New(t)
+	db, err := sqldb.Open("sqlite3", ":memory:")
+	r.NoError(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE users (name TEXT, age INTEGER)")
+	r.NoError(err)
+	_, err = db.Exec("INSERT INTO missing (name) VALUES (?)", "foo")
+	r.Error(err)
+	var count int
+	r.NoError(db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count))
+
+	r.NoError(db.Transaction(func(tx *sqldb.Tx) error {
+		_, err := tx.Exec("INSERT INTO users (name, age) VALUES (?, ?)", "foo", 20)
+		return err
+	}))
+	r.Error(db.Transaction(func(tx *sqldb.Tx) error {
+		return errors.New("boom")
+	}))
+
+	stats := db.Stats()
+	r.Equal(uint64(4), stats.Queries)
+	r.Equal(uint64(1), stats.Errors)
+	r.Equal(uint64(1), stats.Commits)
+	r.Equal(uint64(1), stats.Rollbacks)
+	r.Equal(1, stats.OpenConnections)
+	r.True(stats.Health.Healthy)
+}
+
+func TestHealth(t *testing.T) {
+	r := require.New(t)
+	changes := make(chan sqldb.Health, 1)
+	db, err := sqldb.Open("sqlite3", ":memory:",
+		sqldb.WithHealthCheck(5*time.Millisecond, time.Second),
+		sqldb.WithHealthHook(func(h sqldb.Health) {
+			changes <- h
+		}),
+	)
+	r.NoError(err)
+	defer db.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	health := db.Health()
+	r.True(health.Healthy)
+	r.False(health.CheckedAt.IsZero())
+	r.Len(changes, 0)
+
+	// close the pool underneath the checker
+	r.NoError(db.DB.Close())
+	select {
+	case h := <-changes:
+		r.False(h.Healthy)
+		r.Error(h.Err)
+	case <-time.After(time.Second):
+		t.Fatal("health hook was not called")
+	}
+	r.False(db.Health().Healthy)
+}
+
+func TestOpenUnsupportedDriver(t *testing.T) {
+	r := require.New(t)
+	changes := make(chan sqldb.Health, 1)
+	db, err := sqldb.Open(sqldbtest.DriverName, "unknown",
+		sqldb.WithHealthCheck(5*time.Millisecond, time.Second),
+		sqldb.WithHealthHook(func(h sqldb.Health) {
+			changes <- h
+		}),
+	)
+	r.Error(err)
+	r.Nil(db)
+
+	// no health checker is left running
+	select {
+	case <-changes:
+		t.Fatal("health hook was called")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
@@ -1,6 +1,6 @@
 package models
 
 type Test struct {
-	ID   int    `db:"id"`
+	ID   int    `db:"id,omitempty"`
 	Name string `db:"name"`
 }
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"errors"
+	"goutils/sqldb"
+	"goutils/sqldb/sqldbtest"
+	"goutils/sqldb/test/models"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqldbtest(t *testing.T) {
+	t.Run("postgres placeholders", func(t *testing.T) {
+		r := require.New(t)
+		db, mock := sqldbtest.New(sqldbtest.WithQueryMatcher(sqldbtest.MatchEqual))
+		defer db.Close()
+		sdb := sqldb.NewSqlDB(db, sqldb.PostgreSQL)
+
+		mock.ExpectQuery(`SELECT * FROM users WHERE name = $1 AND age > $2`).
+			WithArgs("foo", 18).
+			WillReturnRows(sqldbtest.NewRows("name", "age").AddRow("foo", 20))
+		mock.ExpectExec(`UPDATE "users" SET "age"=$1 WHERE name = $2`).
+			WithArgs(55, "foo").
+			WillReturnResult(sqldbtest.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO tags (names) VALUES ($1)`).
+			WithArgs(`{"a","b c"}`)
+
+		var users []models.Users
+		r.NoError(sdb.StructScan(&users, "SELECT * FROM users WHERE name = ? AND age > ?", "foo", 18))
+		r.Len(users, 1)
+		r.Equal("foo", users[0].Name.String)
+		r.Equal(int64(20), users[0].Age.Int64)
+
+		res, err := sdb.Table("users").Where("name", "=", "foo").Update(map[string]any{"age": 55})
+		r.NoError(err)
+		affected, err := res.RowsAffected()
+		r.NoError(err)
+		r.Equal(int64(1), affected)
+
+		_, err = sdb.Exec("INSERT INTO tags (names) VALUES (?)", sqldb.Array[string]{"a", "b c"})
+		r.NoError(err)
+		r.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("quoted regexp", func(t *testing.T) {
+		r := require.New(t)
+		db, mock := sqldbtest.New()
+		defer db.Close()
+		sdb := sqldb.NewSqlDB(db, sqldb.PostgreSQL)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM users WHERE name = $1`)).
+			WithArgs("foo").
+			WillReturnRows(sqldbtest.NewRows("name", "age").AddRow("foo", 20))
+
+		var users []models.Users
+		r.NoError(sdb.StructScan(&users, "SELECT * FROM users WHERE name = ?", "foo"))
+		r.Len(users, 1)
+		r.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("transaction", func(t *testing.T) {
+		r := require.New(t)
+		db, mock := sqldbtest.New()
+		defer db.Close()
+		sdb := sqldb.NewSqlDB(db, sqldb.MySQL)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`^DELETE FROM users`).WithArgs(sqldbtest.AnyArg())
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(`^DELETE FROM users`).WillReturnError(errors.New("boom"))
+		mock.ExpectRollback()
+
+		deleteUser := func(tx *sqldb.Tx) error {
+			_, err := tx.Exec("DELETE FROM users WHERE name = ?", "foo")
+			return err
+		}
+		r.NoError(sdb.Transaction(deleteUser))
+		r.EqualError(sdb.Transaction(deleteUser), "boom")
+		r.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("unmet and unexpected", func(t *testing.T) {
+		r := require.New(t)
+		db, mock := sqldbtest.New()
+		defer db.Close()
+		sdb := sqldb.NewSqlDB(db, sqldb.SQLite)
+
+		mock.ExpectExec(`^INSERT`)
+		_, err := sdb.Exec("DELETE FROM users")
+		r.Error(err)
+		r.Error(mock.ExpectationsWereMet())
+
+		_, err = sdb.Exec("INSERT INTO users (name) VALUES (?)", "foo")
+		r.NoError(err)
+		r.NoError(mock.ExpectationsWereMet())
+		_, err = sdb.Exec("INSERT INTO users (name) VALUES (?)", "foo")
+		r.Error(err)
+	})
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"goutils/sqldb"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+type attrs struct {
+	Color string `json:"color"`
+	Size  int    `json:"size"`
+}
+
+type item struct {
+	Name  string                     `db:"name"`
+	Attrs attrs                      `db:"attrs,json"`
+	Meta  sqldb.JSON[map[string]int] `db:"meta"`
+	Tags  sqldb.Array[string]        `db:"tags"`
+}
+
+func TestTypes(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	db, err := sqldb.Open("sqlite3", ":memory:")
+	r.NoError(err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE items (name TEXT, attrs TEXT, meta TEXT, tags TEXT)")
+	r.NoError(err)
+
+	in := item{
+		Name:  "foo",
+		Attrs: attrs{Color: "red", Size: 3},
+		Meta:  sqldb.JSON[map[string]int]{V: map[string]int{"a": 1}},
+		Tags:  sqldb.Array[string]{"x", "y z"},
+	}
+	_, err = sqldb.Insert(ctx, db.Flavor, "", db, "items", in)
+	r.NoError(err)
+	_, err = db.Table("items").Insert(&item{Name: "bar"})
+	r.NoError(err)
+
+	var raw string
+	r.NoError(db.QueryRow("SELECT attrs FROM items WHERE name = ?", "foo").Scan(&raw))
+	r.JSONEq(`{"color":"red","size":3}`, raw)
+
+	var items []item
+	r.NoError(db.StructScan(&items, "SELECT * FROM items ORDER BY name DESC"))
+	r.Len(items, 2)
+	r.Equal(in, items[0])
+	r.Equal("bar", items[1].Name)
+	r.Nil(items[1].Tags)
+
+	var got item
+	r.NoError(db.Get(&got, "SELECT * FROM items WHERE name = ?", "foo"))
+	r.Equal(in, got)
+}
+
+func TestArray(t *testing.T) {
+	r := require.New(t)
+
+	var ints sqldb.Array[int]
+	r.NoError(ints.Scan([]byte("{1, 2,3}")))
+	r.Equal(sqldb.Array[int]{1, 2, 3}, ints)
+	r.NoError(ints.Scan("[1:2]={4,5}"))
+	r.Equal(sqldb.Array[int]{4, 5}, ints)
+	r.NoError(ints.Scan("[6,7]"))
+	r.Equal(sqldb.Array[int]{6, 7}, ints)
+	r.NoError(ints.Scan("{}"))
+	r.Equal(sqldb.Array[int]{}, ints)
+	r.NoError(ints.Scan(nil))
+	r.Nil(ints)
+	r.Error(ints.Scan("{{1,2},{3,4}}"))
+	r.Error(ints.Scan("{a}"))
+
+	var strs sqldb.Array[string]
+	r.NoError(strs.Scan(`{a,"b c","d\"e",NULL,"NULL"}`))
+	r.Equal(sqldb.Array[string]{"a", "b c", `d"e`, "", "NULL"}, strs)
+
+	var bools sqldb.Array[bool]
+	r.NoError(bools.Scan("{t,f}"))
+	r.Equal(sqldb.Array[bool]{true, false}, bools)
+
+	value, err := sqldb.Array[string]{"a", `b"c`}.Value()
+	r.NoError(err)
+	r.Equal(`["a","b\"c"]`, value)
+}
+
+// recordingExecer records the arguments of the last statement it executes.
+type recordingExecer struct {
+	query string
+	args  []any
+}
+
+func (e *recordingExecer) Exec(query string, args ...any) (sql.Result, error) {
+	return e.ExecContext(context.Background(), query, args...)
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.query, e.args = query, args
+	return nil, nil
+}
+
+func TestInsertRawExecer(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	execer := new(recordingExecer)
+	_, err := sqldb.Insert(ctx, sqldb.PostgreSQL, "", execer, "tags", map[string]any{"names": sqldb.Array[string]{"a"}})
+	r.NoError(err)
+	r.Equal(`INSERT INTO "tags" ("names") VALUES ($1)`, execer.query)
+	r.Equal([]any{`{"a"}`}, execer.args)
+
+	_, err = sqldb.Insert(ctx, sqldb.SQLite, "", execer, "tags", map[string]any{"names": sqldb.Array[string]{"a"}})
+	r.NoError(err)
+	r.Equal([]any{`["a"]`}, execer.args)
+}
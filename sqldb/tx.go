@@ -8,8 +8,25 @@ import (
 
 type Tx struct {
 	*sql.Tx
-	Flavor Flavor
-	Option option
+	Flavor   Flavor
+	Option   option
+	counters *counters
+}
+
+func (tx *Tx) Commit() error {
+	err := tx.Tx.Commit()
+	if err == nil && tx.counters != nil {
+		tx.counters.commits.Add(1)
+	}
+	return err
+}
+
+func (tx *Tx) Rollback() error {
+	err := tx.Tx.Rollback()
+	if err == nil && tx.counters != nil {
+		tx.counters.rollbacks.Add(1)
+	}
+	return err
 }
 
 func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
@@ -23,11 +40,14 @@ func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.R
 		fmt.Println()
 	}
 	query = fixQuery(tx.Flavor, query)
+	args = bindArgs(tx.Flavor, args)
 	if opt.Debug {
 		start := Now()
 		defer opt.Log("query: %s, args: %v, time: %v\n", query, args, Since(start))
 	}
-	return tx.Tx.ExecContext(ctx, query, args...)
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.counters.count(err)
+	return res, err
 }
 
 func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
@@ -41,11 +61,14 @@ func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql
 		fmt.Println()
 	}
 	query = fixQuery(tx.Flavor, query)
+	args = bindArgs(tx.Flavor, args)
 	if opt.Debug {
 		start := Now()
 		defer opt.Log("query: %s, args: %v, time: %v\n", query, args, Since(start))
 	}
-	return tx.Tx.QueryContext(ctx, query, args...)
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.counters.count(err)
+	return rows, err
 }
 
 func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
@@ -59,11 +82,14 @@ func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *s
 		fmt.Println()
 	}
 	query = fixQuery(tx.Flavor, query)
+	args = bindArgs(tx.Flavor, args)
 	if opt.Debug {
 		start := Now()
 		defer opt.Log("query: %s, args: %v, time: %v\n", query, args, Since(start))
 	}
-	return tx.Tx.QueryRowContext(ctx, query, args...)
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tx.counters.count(row.Err())
+	return row
 }
 
 func (tx *Tx) Prepare(query string) (*sql.Stmt, error) {
@@ -0,0 +1,353 @@
+package sqldb
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSON stores V as JSON text and decodes it back when scanned.
+// A NULL column scans into the zero value of T.
+type JSON[T any] struct {
+	V T
+}
+
+// Scan implements the sql.Scanner interface.
+func (j *JSON[T]) Scan(src any) error {
+	var zero T
+	j.V = zero
+	return scanJSON(src, &j.V)
+}
+
+// Value implements the driver.Valuer interface.
+func (j JSON[T]) Value() (driver.Value, error) {
+	return valueJSON(j.V)
+}
+
+// MarshalJSON makes JSON transparent when the containing struct is itself encoded.
+func (j JSON[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.V)
+}
+
+// UnmarshalJSON makes JSON transparent when the containing struct is itself decoded.
+func (j *JSON[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &j.V)
+}
+
+// Array is a one-dimensional array column.
+//
+// On PostgreSQL it is encoded as an array literal such as {1,2,3}, on MySQL and SQLite
+// it is stored as JSON text. Scan accepts both forms regardless of the flavor.
+// Value always returns JSON text, DB and Tx rewrite it into an array literal for PostgreSQL.
+type Array[T any] []T
+
+// Scan implements the sql.Scanner interface.
+func (a *Array[T]) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("sqldb: cannot scan %T into %T", src, a)
+	}
+	text = strings.TrimSpace(text)
+	if i := strings.Index(text, "]={"); i > 0 && text[0] == '[' {
+		// skip the optional dimension decoration, e.g. [1:3]={1,2,3}
+		text = text[i+2:]
+	}
+	if strings.HasPrefix(text, "{") {
+		elems, err := parseArrayLiteral(text)
+		if err != nil {
+			return err
+		}
+		arr := make(Array[T], len(elems))
+		for i, elem := range elems {
+			if elem == nil {
+				continue
+			}
+			if err := convertArrayElem(*elem, &arr[i]); err != nil {
+				return fmt.Errorf("sqldb: array element %d: %w", i, err)
+			}
+		}
+		*a = arr
+		return nil
+	}
+	var arr []T
+	if err := json.Unmarshal([]byte(text), &arr); err != nil {
+		return fmt.Errorf("sqldb: cannot scan %q into %T: %w", text, a, err)
+	}
+	*a = arr
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (a Array[T]) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return valueJSON([]T(a))
+}
+
+func (a Array[T]) flavorValue(flavor Flavor) (driver.Value, error) {
+	if flavor != PostgreSQL {
+		return a.Value()
+	}
+	if a == nil {
+		return nil, nil
+	}
+	builder := acquireStringBuilder()
+	defer releaseStringBuilder(builder)
+	builder.WriteByte('{')
+	for i := range a {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		if err := writeArrayElem(builder, reflect.ValueOf(&a[i]).Elem()); err != nil {
+			return nil, fmt.Errorf("sqldb: array element %d: %w", i, err)
+		}
+	}
+	builder.WriteByte('}')
+	return builder.String(), nil
+}
+
+// flavorValuer is implemented by values whose encoding depends on the flavor.
+type flavorValuer interface {
+	flavorValue(flavor Flavor) (driver.Value, error)
+}
+
+// bindArgs replaces flavor dependent arguments by their encoded value.
+// args is only copied when at least one argument needs to be replaced.
+// Encoding errors are reported by the driver when it converts the argument.
+func bindArgs(flavor Flavor, args []any) []any {
+	var bound []any
+	for i, arg := range args {
+		v, ok := arg.(flavorValuer)
+		if !ok {
+			continue
+		}
+		if bound == nil {
+			bound = make([]any, len(args))
+			copy(bound, args)
+		}
+		value, err := v.flavorValue(flavor)
+		if err != nil {
+			bound[i] = errValue{err}
+			continue
+		}
+		bound[i] = value
+	}
+	if bound == nil {
+		return args
+	}
+	return bound
+}
+
+// errValue is a driver.Valuer which always fails with err.
+type errValue struct {
+	err error
+}
+
+// Value implements the driver.Valuer interface.
+func (e errValue) Value() (driver.Value, error) {
+	return nil, e.err
+}
+
+// parseArrayLiteral splits a one-dimensional PostgreSQL array literal into its elements.
+// NULL elements are returned as nil.
+func parseArrayLiteral(text string) ([]*string, error) {
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return nil, fmt.Errorf("sqldb: invalid array literal %q", text)
+	}
+	body := text[1 : len(text)-1]
+	if strings.TrimSpace(body) == "" {
+		return []*string{}, nil
+	}
+	var (
+		elems []*string
+		elem  strings.Builder
+	)
+	for i := 0; i <= len(body); {
+		// leading whitespace of an element
+		for i < len(body) && body[i] == ' ' {
+			i++
+		}
+		elem.Reset()
+		quoted := false
+		switch {
+		case i < len(body) && body[i] == '{':
+			return nil, fmt.Errorf("sqldb: multi-dimensional array literal %q is not supported", text)
+		case i < len(body) && body[i] == '"':
+			quoted = true
+			i++
+			for ; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' {
+					i++
+					if i == len(body) {
+						break
+					}
+				}
+				elem.WriteByte(body[i])
+			}
+			if i >= len(body) {
+				return nil, fmt.Errorf("sqldb: unterminated quoted element in array literal %q", text)
+			}
+			i++
+			for i < len(body) && body[i] == ' ' {
+				i++
+			}
+		default:
+			for ; i < len(body) && body[i] != ','; i++ {
+				elem.WriteByte(body[i])
+			}
+		}
+		if i < len(body) && body[i] != ',' {
+			return nil, fmt.Errorf("sqldb: invalid array literal %q", text)
+		}
+		i++
+
+		s := elem.String()
+		if !quoted {
+			s = strings.TrimSpace(s)
+			if strings.EqualFold(s, "NULL") {
+				elems = append(elems, nil)
+				continue
+			}
+		}
+		elems = append(elems, &s)
+	}
+	return elems, nil
+}
+
+// convertArrayElem converts the text of a single array element into dest.
+func convertArrayElem(text string, dest any) error {
+	if scanner, ok := dest.(interface{ Scan(any) error }); ok {
+		return scanner.Scan(text)
+	}
+	v := reflect.ValueOf(dest).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(text), dest)
+	}
+	return nil
+}
+
+// writeArrayElem writes v as a single PostgreSQL array literal element.
+func writeArrayElem(builder *strings.Builder, v reflect.Value) error {
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			builder.WriteString("NULL")
+			return nil
+		}
+		v = reflect.ValueOf(value)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		quoteArrayElem(builder, v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			builder.WriteByte('t')
+		} else {
+			builder.WriteByte('f')
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		builder.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		builder.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		builder.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()))
+	default:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		quoteArrayElem(builder, string(b))
+	}
+	return nil
+}
+
+func quoteArrayElem(builder *strings.Builder, s string) {
+	builder.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(s[i])
+	}
+	builder.WriteByte('"')
+}
+
+// jsonField scans and encodes a struct field tagged with the json option.
+type jsonField struct {
+	ptr any
+}
+
+// Scan implements the sql.Scanner interface.
+func (f jsonField) Scan(src any) error {
+	v := reflect.ValueOf(f.ptr).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return scanJSON(src, f.ptr)
+}
+
+// Value implements the driver.Valuer interface.
+func (f jsonField) Value() (driver.Value, error) {
+	return valueJSON(reflect.ValueOf(f.ptr).Elem().Interface())
+}
+
+func scanJSON(src any, dest any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	}
+	return errors.New("sqldb: cannot scan " + reflect.TypeOf(src).String() + " into JSON")
+}
+
+func valueJSON(v any) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}